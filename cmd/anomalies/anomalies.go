@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github-issue-data/pkg"
+)
+
+// AnomalyReport sums up how inflated the star history of a repo looks.
+type AnomalyReport struct {
+	RepoID            int     `json:"repo_id"`
+	Stars             int     `json:"stars"`
+	BurstIntervals    int     `json:"burst_intervals"`
+	MaxZScore         float64 `json:"max_z_score"`
+	NewAccounts       int     `json:"new_accounts"`
+	EmptyAccounts     int     `json:"empty_accounts"`
+	ClusteredAccounts int     `json:"clustered_accounts"`
+	SuspiciousStars   int     `json:"suspicious_stars"`
+	SuspiciousShare   float64 `json:"suspicious_share"`
+	Suspicious        bool    `json:"suspicious"`
+}
+
+func (report AnomalyReport) SQLiteUpserts() []github.Upsert {
+	return []github.Upsert{
+		github.RepoUpsert(report.RepoID),
+		{
+			Table: "star_anomalies",
+			Key:   []string{"repo_id"},
+			Values: map[string]any{
+				"repo_id":            report.RepoID,
+				"stars":              report.Stars,
+				"burst_intervals":    report.BurstIntervals,
+				"max_z_score":        report.MaxZScore,
+				"new_accounts":       report.NewAccounts,
+				"empty_accounts":     report.EmptyAccounts,
+				"clustered_accounts": report.ClusteredAccounts,
+				"suspicious_stars":   report.SuspiciousStars,
+				"suspicious_share":   report.SuspiciousShare,
+				"suspicious":         report.Suspicious,
+			},
+		},
+	}
+}
+
+type detectOptions struct {
+	window         int
+	zScore         float64
+	minBurst       int
+	newAccountDays int
+	clusterSize    int
+	maxShare       float64
+}
+
+func main() {
+	options := &detectOptions{}
+	flag.IntVar(&options.window, "window", 8, "number of intervals in the rolling baseline")
+	flag.Float64Var(&options.zScore, "z", 3, "z-score against the baseline above which an interval is a burst")
+	flag.IntVar(&options.minBurst, "min-burst", 10, "fewest stars in an interval for it to count as a burst")
+	flag.IntVar(&options.newAccountDays, "new-account-days", 30, "accounts starring within this many days of being created are new")
+	flag.IntVar(&options.clusterSize, "cluster-size", 5, "accounts created on the same day and starring in the same interval that form a cluster")
+	flag.Float64Var(&options.maxShare, "max-share", 0.1, "share of suspicious stars above which a repo is flagged")
+	format := flag.String("format", github.DefaultFormat(), github.FormatUsage())
+	profiles := flag.Bool("profiles", true, "fetch stargazer profiles to check accounts, otherwise only detect bursts")
+	flag.Parse()
+
+	starEvents, err := github.LoadStarEvents("data/star_events.csv")
+	if err != nil {
+		fmt.Println("Error reading star events.")
+		panic(err)
+	}
+
+	fmt.Println("Loaded star events.")
+
+	users := map[string]github.UserProfile{}
+	if *profiles {
+		token := os.Getenv("GITHUB_TOKEN")
+
+		if token == "" {
+			fmt.Println("Please set the GITHUB_TOKEN environment variable.")
+		}
+
+		client := github.NewClient(token)
+
+		fmt.Println("Fetching stargazer profiles.")
+
+		users, err = fetchProfiles(client, starEvents)
+		if err != nil {
+			fmt.Println("Error fetching profiles.")
+			panic(err)
+		}
+	}
+
+	reportWriter, err := github.OpenSink(*format, "data/star_anomalies", AnomalyReport{})
+	if err != nil {
+		fmt.Println("Error opening anomaly report.")
+		panic(err)
+	}
+	defer reportWriter.Close()
+
+	cleanWriter, err := github.OpenSink(*format, "data/stargazers_clean", github.StarHistory{})
+	if err != nil {
+		fmt.Println("Error opening clean star history.")
+		panic(err)
+	}
+	defer cleanWriter.Close()
+
+	for start := 0; start < len(starEvents); {
+		end := start
+		for end < len(starEvents) && starEvents[end].RepoID == starEvents[start].RepoID {
+			end++
+		}
+
+		report, clean := detectAnomalies(starEvents[start:end], users, options)
+		if err := reportWriter.Write(report); err != nil {
+			fmt.Println("Error writing anomaly report.")
+			panic(err)
+		}
+		// kept apart from the history of cmd/stargazers in SQLite
+		history := github.AggregateStarEvents(clean)
+		for i := range history {
+			history[i].Series = "stars_clean"
+		}
+		if err := github.WriteRows(cleanWriter, history...); err != nil {
+			fmt.Println("Error writing clean star history.")
+			panic(err)
+		}
+
+		if report.Suspicious {
+			fmt.Printf("Repo %d looks inflated: %d burst intervals, %.1f%% suspicious stars\n",
+				report.RepoID, report.BurstIntervals, 100*report.SuspiciousShare)
+		}
+
+		start = end
+	}
+}
+
+// fetchProfiles fetches the profile of every stargazer once, keyed by login.
+func fetchProfiles(client *github.Client, starEvents []github.StarEvent) (map[string]github.UserProfile, error) {
+	seen := map[string]bool{}
+	logins := []string{}
+	for _, starEvent := range starEvents {
+		if starEvent.UserLogin == "" || seen[starEvent.UserLogin] {
+			continue
+		}
+		seen[starEvent.UserLogin] = true
+		logins = append(logins, starEvent.UserLogin)
+	}
+
+	profiles, err := client.FetchUsers(logins)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]github.UserProfile, len(profiles))
+	for _, profile := range profiles {
+		users[profile.Login] = profile
+	}
+
+	fmt.Println("Profiles fetched:", len(users), "/", len(logins))
+
+	return users, nil
+}
+
+// detectAnomalies reports the bursts and suspicious stargazers of a repo and
+// returns its star events without the suspicious ones. A star is suspicious
+// when its account is part of a cluster, or is both new and empty, since
+// either trait alone is common among real users.
+func detectAnomalies(starEvents []github.StarEvent, users map[string]github.UserProfile, options *detectOptions) (AnomalyReport, []github.StarEvent) {
+	report := AnomalyReport{RepoID: starEvents[0].RepoID, Stars: len(starEvents)}
+
+	// bursts in stars per interval against the intervals before them
+	counts := map[int]int{}
+	firstStar := starEvents[0].StarredAt
+	lastInterval := 0
+	for _, starEvent := range starEvents {
+		if starEvent.StarredAt.Before(firstStar) {
+			firstStar = starEvent.StarredAt
+		}
+		if starEvent.StarredAt.Year() < 2016 || starEvent.StarredAt.Year() > 2019 {
+			continue
+		}
+		interval := github.StarInterval(starEvent.StarredAt)
+		counts[interval]++
+		if interval > lastInterval {
+			lastInterval = interval
+		}
+	}
+
+	// the weeks before the first star are not quiet weeks, so a baseline
+	// reaching back before it would make every launch look like a burst
+	firstInterval := max(github.StarInterval(firstStar), 1)
+	for interval := firstInterval + options.window; interval <= lastInterval; interval++ {
+		mean, std := baseline(counts, interval-options.window, interval)
+		z := (float64(counts[interval]) - mean) / math.Max(std, 1)
+		if z > report.MaxZScore {
+			report.MaxZScore = z
+		}
+		if z >= options.zScore && counts[interval] >= options.minBurst {
+			report.BurstIntervals++
+		}
+	}
+
+	// accounts created on the same day starring in the same interval
+	type clusterKey struct {
+		created  string
+		interval int
+	}
+	clusters := map[clusterKey]int{}
+	for _, starEvent := range starEvents {
+		if user, ok := users[starEvent.UserLogin]; ok {
+			clusters[clusterKey{user.CreatedAt.Format("2006-01-02"), github.StarInterval(starEvent.StarredAt)}]++
+		}
+	}
+
+	newAccount := time.Duration(options.newAccountDays) * 24 * time.Hour
+
+	clean := make([]github.StarEvent, 0, len(starEvents))
+	for _, starEvent := range starEvents {
+		user, ok := users[starEvent.UserLogin]
+		if !ok {
+			clean = append(clean, starEvent)
+			continue
+		}
+
+		isNew := starEvent.StarredAt.Sub(user.CreatedAt) < newAccount
+		isEmpty := user.PublicRepos == 0 && user.Followers == 0
+		isClustered := clusters[clusterKey{user.CreatedAt.Format("2006-01-02"), github.StarInterval(starEvent.StarredAt)}] >= options.clusterSize
+
+		if isNew {
+			report.NewAccounts++
+		}
+		if isEmpty {
+			report.EmptyAccounts++
+		}
+		if isClustered {
+			report.ClusteredAccounts++
+		}
+
+		if isClustered || (isNew && isEmpty) {
+			report.SuspiciousStars++
+			continue
+		}
+
+		clean = append(clean, starEvent)
+	}
+
+	report.SuspiciousShare = float64(report.SuspiciousStars) / float64(report.Stars)
+	report.Suspicious = report.BurstIntervals > 0 || report.SuspiciousShare > options.maxShare
+
+	return report, clean
+}
+
+// baseline is the mean and standard deviation of stars per interval in
+// [from, to).
+func baseline(counts map[int]int, from int, to int) (float64, float64) {
+	n := float64(to - from)
+
+	sum := 0.0
+	for interval := from; interval < to; interval++ {
+		sum += float64(counts[interval])
+	}
+	mean := sum / n
+
+	variance := 0.0
+	for interval := from; interval < to; interval++ {
+		variance += math.Pow(float64(counts[interval])-mean, 2)
+	}
+
+	return mean, math.Sqrt(variance / n)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github-issue-data/pkg"
+)
+
+func TestDetectAnomaliesBursts(t *testing.T) {
+	options := &detectOptions{window: 8, zScore: 3, minBurst: 10, clusterSize: 5, maxShare: 0.1}
+
+	// stars gives each week, from the first week of 2018, its number of stars
+	stars := func(weeks ...int) []github.StarEvent {
+		var starEvents []github.StarEvent
+		start := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+		for week, count := range weeks {
+			for i := 0; i < count; i++ {
+				starEvents = append(starEvents, github.StarEvent{RepoID: 1, StarredAt: start.AddDate(0, 0, 7*week)})
+			}
+		}
+		return starEvents
+	}
+
+	tests := []struct {
+		name   string
+		weeks  []int
+		bursts int
+	}{
+		{"launch week", []int{50, 20, 10, 5, 5, 5, 5, 5, 5, 5, 5, 5}, 0},
+		{"burst after a quiet baseline", []int{2, 2, 2, 2, 2, 2, 2, 2, 2, 60, 2}, 1},
+		{"too few stars", []int{1, 0, 0, 0, 0, 0, 0, 0, 0, 8}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, _ := detectAnomalies(stars(test.weeks...), nil, options)
+			if report.BurstIntervals != test.bursts {
+				t.Errorf("%d burst intervals, want %d", report.BurstIntervals, test.bursts)
+			}
+			if report.Suspicious != (test.bursts > 0) {
+				t.Errorf("suspicious = %v", report.Suspicious)
+			}
+		})
+	}
+}
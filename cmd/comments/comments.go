@@ -1,18 +1,20 @@
 package main
 
 import (
-	"encoding/csv"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
-	"strconv"
 	"time"
 
 	"github-issue-data/pkg"
-	issuesquery "github-issue-data/pkg/issue"
+	commentsquery "github-issue-data/pkg/comment"
+	issuesearch "github-issue-data/pkg/issue/search"
 )
 
 type CommentData struct {
-	RepoId      int    `json:"repo_id"`
+	RepoId      int    `json:"repo_id" csv:",required"`
 	IssueNumber int    `json:"issue_number"`
 	CommentID   int    `json:"comment_id"`
 	AuthorID    int    `json:"author_id"`
@@ -20,9 +22,72 @@ type CommentData struct {
 	Interval    int    `json:"interval"`
 	Text        string `json:"text"`
 	Type        string `json:"type"`
+	// last, so files written before it was added can still be appended to
+	IssueID int `json:"issue_id"`
+	// kept nested in JSON Lines only, CSV has the flattened columns above
+	User      github.User            `json:"user" csv:"-"`
+	Labels    []github.Label         `json:"labels,omitempty" csv:"-"`
+	Reactions []github.ReactionGroup `json:"reactions,omitempty" csv:"-"`
+}
+
+// DoneRepo marks a repo whose comments were all written, including repos
+// without any, so -resume can skip it.
+type DoneRepo struct {
+	RepoID int `json:"repo_id" csv:",required"`
+}
+
+// SQLiteUpserts writes the opening post of an issue into issues and any other
+// comment into comments, along with its author.
+func (comment CommentData) SQLiteUpserts() []github.Upsert {
+	upserts := []github.Upsert{
+		github.RepoUpsert(comment.RepoId),
+		github.UserUpsert(github.User{ID: comment.AuthorID, Login: comment.Author}),
+	}
+
+	issue := github.Upsert{
+		Table: "issues",
+		Key:   []string{"id"},
+		Values: map[string]any{
+			"id":      comment.IssueID,
+			"repo_id": comment.RepoId,
+			"number":  comment.IssueNumber,
+		},
+	}
+
+	if comment.CommentID == -1 {
+		issue.Values["user_id"] = comment.AuthorID
+		issue.Values["interval"] = comment.Interval
+		issue.Values["body"] = comment.Text
+		issue.Values["author_association"] = comment.Type
+		return append(upserts, issue)
+	}
+
+	return append(upserts, issue, github.Upsert{
+		Table: "comments",
+		Key:   []string{"id"},
+		Values: map[string]any{
+			"id":                 comment.CommentID,
+			"issue_id":           comment.IssueID,
+			"repo_id":            comment.RepoId,
+			"user_id":            comment.AuthorID,
+			"interval":           comment.Interval,
+			"body":               comment.Text,
+			"author_association": comment.Type,
+		},
+	})
 }
 
 func main() {
+	backend := flag.String("backend", "rest", "collector backend, rest or graphql")
+	resume := flag.Bool("resume", false, "append to data/comments, skipping repos marked done in data/comments_done.csv")
+	format := flag.String("format", github.DefaultFormat(), github.FormatUsage())
+	flag.Parse()
+
+	if *backend != "rest" && *backend != "graphql" {
+		fmt.Println("Unknown backend:", *backend)
+		os.Exit(1)
+	}
+
 	token := os.Getenv("GITHUB_TOKEN")
 
 	if token == "" {
@@ -32,103 +97,169 @@ func main() {
 	client := github.NewClient(token)
 
 	sampleFilePath := "data/sample.csv"
+	commentsPath := "data/comments"
+	donePath := "data/comments_done"
+
+	done := map[int]bool{}
+	var writer, doneWriter github.Sink
+	var err error
+	if *resume {
+		done, err = readDoneRepos(donePath + ".csv")
+		if err == nil {
+			writer, err = github.AppendSink(*format, commentsPath, CommentData{})
+		}
+		if err == nil {
+			doneWriter, err = github.AppendSink("csv", donePath, DoneRepo{})
+		}
+	} else {
+		writer, err = github.OpenSink(*format, commentsPath, CommentData{})
+		if err == nil {
+			doneWriter, err = github.OpenSink("csv", donePath, DoneRepo{})
+		}
+	}
+	if err != nil {
+		fmt.Println("Error on opening comments.\n[ERROR] -", err)
+		os.Exit(1)
+	}
+	defer writer.Close()
+	defer doneWriter.Close()
 
-	comments, err := getComments(client, sampleFilePath)
+	count, err := getComments(client, sampleFilePath, *backend, done, writer, doneWriter)
 	if err != nil {
 		fmt.Println("Error on getting comments.\n[ERROR] -", err)
 		fmt.Print(client.RequestCount)
 	}
 
-	if comments != nil {
-		fmt.Println("Number of comments:", len(*comments))
-		github.SaveToCSV(comments, "data/comments.csv")
-	}
+	fmt.Println("Number of comments:", count)
 }
 
-func getComments(client *github.Client, sampleFilePath string) (*[]CommentData, error) {
-	dataset := []CommentData{}
+// readDoneRepos returns the repos marked done by an earlier run, so a crashed
+// run can pick up where it stopped.
+func readDoneRepos(doneFilePath string) (map[int]bool, error) {
+	done := map[int]bool{}
 
-	file, err := os.Open(sampleFilePath)
-	if err != nil {
-		fmt.Println("Error opening CSV file:", err)
+	doneRepos, err := github.LoadFromCSV[DoneRepo](doneFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return done, nil
+	} else if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	allRecords, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+	for _, doneRepo := range doneRepos {
+		done[doneRepo.RepoID] = true
 	}
 
-	header := allRecords[0]
-	columnIndex := make(map[string]int)
-	for i, columnName := range header {
-		columnIndex[columnName] = i
-	}
+	return done, nil
+}
 
-	allRecords = allRecords[1:]
+// getComments writes the comments of every sampled repo as soon as the repo is
+// done, then marks the repo done, returning how many comments were written.
+func getComments(client *github.Client, sampleFilePath string, backend string, done map[int]bool, writer github.Sink, doneWriter github.Sink) (int, error) {
+	count := 0
 
-	for i, record := range allRecords {
-		parsedComments := len(dataset)
+	repos, err := github.LoadFromCSV[github.Repo](sampleFilePath)
+	if err != nil {
+		fmt.Println("Error reading CSV file:", err)
+		return count, err
+	}
 
-		repo := github.Repo{}
-		for columnName, index := range columnIndex {
-			switch columnName {
-			case "id":
-				repo.ID, _ = strconv.Atoi(record[index])
-			case "name":
-				repo.Name = record[index]
-			case "full_name":
-				repo.FullName = record[index]
-			case "stargazers_count":
-				repo.Stars, _ = strconv.Atoi(record[index])
-			}
+	for i, repo := range repos {
+		if done[repo.ID] {
+			fmt.Println("Repos parsed:", i+1, "/", len(repos), "| Already done")
+			continue
 		}
 
-		issues, err := filterIssues(client, &repo)
+		var issues *[]CommentData
+		if backend == "graphql" {
+			issues, err = filterIssueThreads(client, &repo)
+		} else {
+			issues, err = filterIssues(client, &repo)
+		}
 		if err != nil {
 			fmt.Println("Failed to fetch issues for", repo.FullName, ":", err)
-			return &dataset, err
+			return count, err
+		}
+
+		if err := github.WriteRows(writer, *issues...); err != nil {
+			fmt.Println("Failed to write comments for", repo.FullName, ":", err)
+			return count, err
+		}
+
+		if err := github.WriteRows(doneWriter, DoneRepo{RepoID: repo.ID}); err != nil {
+			fmt.Println("Failed to mark", repo.FullName, "done:", err)
+			return count, err
 		}
 
-		dataset = append(dataset, (*issues)...)
-		fmt.Println("Repos parsed:", i+1, "/", len(allRecords), "| Comments parsed:", len(dataset)-parsedComments)
+		count += len(*issues)
+		fmt.Println("Repos parsed:", i+1, "/", len(repos), "| Comments parsed:", len(*issues))
 	}
 
-	return &dataset, nil
+	return count, nil
 }
 
 func filterIssues(client *github.Client, repo *github.Repo) (*[]CommentData, error) {
 	data := []CommentData{}
 
-	query := issuesquery.NewIssueQuery(
-		issuesquery.State(issuesquery.Closed()),
-		issuesquery.PerPage(100),
+	search := issuesearch.NewSearchParams(
+		issuesearch.Repo(repo.FullName),
+		issuesearch.Is(issuesearch.Issue()),
+		issuesearch.State(issuesearch.Closed()),
 	)
 
-	for page := 1; ; page++ {
-		query.Set(issuesquery.Page(page))
+	from := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, time.December, 31, 0, 0, 0, 0, time.UTC)
 
-		issues, err := client.FetchIssues(repo.FullName, query)
-		if err != nil {
-			fmt.Println("Failed to fetch issues for", repo.FullName, ":", err)
-			return nil, err
+	found, err := client.SearchAllIssues(search, from, to)
+	if err != nil {
+		fmt.Println("Failed to fetch issues for", repo.FullName, ":", err)
+		return nil, err
+	}
+
+	issues := []github.Issue{}
+	for _, issue := range found {
+		if filterIssue(&issue) {
+			issues = append(issues, issue)
 		}
+	}
 
-		if len(issues) == 0 {
-			break
+	if len(issues) == 0 {
+		return &data, nil
+	}
+
+	comments, err := fetchRepoComments(client, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, issue := range issues {
+		thread := github.IssueThread{Issue: issue}
+		for _, comment := range comments[issue.URL] {
+			thread.Comments = append(thread.Comments, github.ThreadComment{Comment: comment})
 		}
+		data = append(data, *convertIssueToComments(repo, &thread)...)
+	}
 
-		for _, issue := range issues {
-			if filterIssue(&issue) {
-				comments, err := convertIssueToComments(client, repo, &issue)
-				if err != nil {
-					return nil, err
-				}
-				data = append(data, (*comments)...)
-			}
+	return &data, nil
+}
+
+// filterIssueThreads is the GraphQL counterpart of filterIssues and yields the
+// same rows.
+func filterIssueThreads(client *github.Client, repo *github.Repo) (*[]CommentData, error) {
+	data := []CommentData{}
+
+	since := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	threads, err := client.FetchIssueThreads(repo.FullName, since, "CLOSED")
+	if err != nil {
+		fmt.Println("Failed to fetch issues for", repo.FullName, ":", err)
+		return nil, err
+	}
+
+	for _, thread := range threads {
+		if !filterIssue(&thread.Issue) {
+			continue
 		}
+
+		data = append(data, *convertIssueToComments(repo, &thread)...)
 	}
 
 	return &data, nil
@@ -139,19 +270,56 @@ func filterIssue(issue *github.Issue) bool {
 	return year > 2016 && year < 2020 && issue.PullRequest == nil && issue.State == "closed"
 }
 
-func convertIssueToComments(client *github.Client, repo *github.Repo, issue *github.Issue) (*[]CommentData, error) {
-	data := []CommentData{}
+// fetchRepoComments lists every comment of the repo created inside the study
+// window and groups them by the issue_url they belong to.
+func fetchRepoComments(client *github.Client, repo *github.Repo) (map[string][]github.Comment, error) {
+	byIssue := make(map[string][]github.Comment)
 
-	comments, err := client.FetchCommentsForIssue(repo.FullName, issue.Number)
-	if err != nil {
-		fmt.Println("Failed to fetch comments for", repo.FullName, ":", issue.ID)
-		return nil, err
+	query := commentsquery.NewCommentQuery(
+		commentsquery.Since(time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		commentsquery.Sort(commentsquery.Created()),
+		commentsquery.Direction(commentsquery.Asc()),
+		commentsquery.PerPage(100),
+	)
+
+	for page := 1; ; page++ {
+		query.Set(commentsquery.Page(page))
+
+		comments, err := client.FetchCommentsForRepo(repo.FullName, query)
+		if err != nil {
+			fmt.Println("Failed to fetch comments for", repo.FullName, ":", err)
+			return nil, err
+		}
+
+		if len(comments) == 0 {
+			break
+		}
+
+		for _, comment := range comments {
+			byIssue[comment.IssueURL] = append(byIssue[comment.IssueURL], comment)
+		}
+
+		// sorted by creation, so nothing after this page is in the window
+		if comments[len(comments)-1].CreatedAt.Year() > 2019 {
+			break
+		}
 	}
 
+	return byIssue, nil
+}
+
+// convertIssueToComments turns an issue and its comments into rows. Reactions
+// are only known when the thread was fetched over GraphQL.
+func convertIssueToComments(repo *github.Repo, thread *github.IssueThread) *[]CommentData {
+	data := []CommentData{}
+
+	issue := &thread.Issue
+
 	interval := dateToInterval(issue.CreatedAt)
 
 	data = append(data, CommentData{
 		RepoId:      repo.ID,
+		IssueID:     issue.ID,
 		IssueNumber: issue.Number,
 		CommentID:   -1,
 		AuthorID:    issue.User.ID,
@@ -159,14 +327,18 @@ func convertIssueToComments(client *github.Client, repo *github.Repo, issue *git
 		Interval:    interval,
 		Text:        issue.Title + " " + issue.Body,
 		Type:        issue.Type,
+		User:        issue.User,
+		Labels:      issue.Labels,
+		Reactions:   thread.Reactions,
 	})
 
-	for _, comment := range comments {
+	for _, comment := range thread.Comments {
 		year := comment.CreatedAt.Year()
 		if year > 2016 && year < 2020 {
 			interval := dateToInterval(comment.CreatedAt)
 			data = append(data, CommentData{
 				RepoId:      repo.ID,
+				IssueID:     issue.ID,
 				IssueNumber: issue.Number,
 				CommentID:   comment.ID,
 				AuthorID:    comment.User.ID,
@@ -174,11 +346,13 @@ func convertIssueToComments(client *github.Client, repo *github.Repo, issue *git
 				Interval:    interval,
 				Text:        comment.Body,
 				Type:        comment.Type,
+				User:        comment.User,
+				Reactions:   comment.Reactions,
 			})
 		}
 	}
 
-	return &data, nil
+	return &data
 }
 
 func dateToInterval(date time.Time) int {
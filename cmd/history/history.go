@@ -1,13 +1,16 @@
 package main
 
 import (
-	"encoding/csv"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github-issue-data/pkg"
+	commitquery "github-issue-data/pkg/commit"
+	"github-issue-data/pkg/gitlog"
 )
 
 type RepoHistory struct {
@@ -16,7 +19,116 @@ type RepoHistory struct {
 	Interval int `json:"interval"`
 }
 
+type CommitRecord struct {
+	RepoID             int       `json:"repo_id"`
+	SHA                string    `json:"sha"`
+	Interval           int       `json:"interval"`
+	AuthorName         string    `json:"author_name"`
+	AuthorEmail        string    `json:"author_email"`
+	AuthorLogin        string    `json:"author_login"`
+	AuthorDate         time.Time `json:"author_date"`
+	CommitterName      string    `json:"committer_name"`
+	CommitterEmail     string    `json:"committer_email"`
+	CommitterLogin     string    `json:"committer_login"`
+	CommitterDate      time.Time `json:"committer_date"`
+	Message            string    `json:"message"`
+	Parents            string    `json:"parents"`
+	Verified           bool      `json:"verified"`
+	VerificationReason string    `json:"verification_reason"`
+	Additions          int       `json:"additions"`
+	Deletions          int       `json:"deletions"`
+	FilesChanged       int       `json:"files_changed"`
+	FilesTruncated     bool      `json:"files_truncated"`
+}
+
+func (history RepoHistory) SQLiteUpserts() []github.Upsert {
+	return []github.Upsert{
+		github.RepoUpsert(history.RepoID),
+		{
+			Table: "intervals",
+			Key:   []string{"repo_id", "series", "interval"},
+			Values: map[string]any{
+				"repo_id":   history.RepoID,
+				"series":    "commits",
+				"interval":  history.Interval,
+				"starts_at": intervalStart(history.Interval),
+				"value":     history.Commits,
+			},
+		},
+	}
+}
+
+func (record CommitRecord) SQLiteUpserts() []github.Upsert {
+	return []github.Upsert{
+		github.RepoUpsert(record.RepoID),
+		{
+			Table: "commits",
+			Key:   []string{"repo_id", "sha"},
+			Values: map[string]any{
+				"repo_id":             record.RepoID,
+				"sha":                 record.SHA,
+				"interval":            record.Interval,
+				"author_name":         record.AuthorName,
+				"author_email":        record.AuthorEmail,
+				"author_login":        record.AuthorLogin,
+				"author_date":         record.AuthorDate,
+				"committer_name":      record.CommitterName,
+				"committer_email":     record.CommitterEmail,
+				"committer_login":     record.CommitterLogin,
+				"committer_date":      record.CommitterDate,
+				"message":             record.Message,
+				"parents":             record.Parents,
+				"verified":            record.Verified,
+				"verification_reason": record.VerificationReason,
+				"additions":           record.Additions,
+				"deletions":           record.Deletions,
+				"files_changed":       record.FilesChanged,
+				"files_truncated":     record.FilesTruncated,
+			},
+		},
+	}
+}
+
+type historyOptions struct {
+	backend  string
+	source   string
+	clones   string
+	sha      string
+	path     string
+	detailed bool
+	mailmap  bool
+	noMerges bool
+	format   string
+}
+
 func main() {
+	options := historyOptions{}
+	flag.StringVar(&options.path, "path", "", "only count commits touching this file or directory")
+	flag.StringVar(&options.sha, "sha", "", "count commits on this branch or from this SHA instead of the default branch")
+	flag.StringVar(&options.backend, "backend", "rest", "api collector backend, rest or graphql with a fallback to rest")
+	flag.BoolVar(&options.detailed, "detailed", false, "also write every commit with its authors, message and churn to data/commit_details.csv")
+	flag.StringVar(&options.source, "source", "api", "where commits come from, the api or local bare git clones")
+	flag.StringVar(&options.clones, "clones", "data/clones", "with -source git, directory holding a bare clone per repo at <owner>/<name>.git")
+	flag.BoolVar(&options.mailmap, "mailmap", true, "with -source git, map authors through the repo's .mailmap")
+	flag.BoolVar(&options.noMerges, "no-merges", false, "with -source git, leave out merge commits")
+	flag.StringVar(&options.format, "format", github.DefaultFormat(), github.FormatUsage())
+	flag.Parse()
+
+	if options.backend != "rest" && options.backend != "graphql" {
+		fmt.Println("Unknown backend:", options.backend)
+		os.Exit(1)
+	}
+
+	if options.source != "api" && options.source != "git" {
+		fmt.Println("Unknown source:", options.source)
+		os.Exit(1)
+	}
+
+	if options.detailed && options.source == "api" && options.backend == "graphql" {
+		fmt.Println("-detailed downloads every commit over REST and cannot be used with -backend graphql.")
+		os.Exit(1)
+	}
+
 	token := os.Getenv("GITHUB_TOKEN")
 
 	if token == "" {
@@ -26,7 +138,7 @@ func main() {
 	client := github.NewClient(token)
 
 	reposFilepath := "data/sample.csv"
-	repos, err := readRepos(reposFilepath)
+	repos, err := github.LoadFromCSV[github.Repo](reposFilepath)
 	if err != nil {
 		fmt.Println("Error fetching repos.")
 		panic(err)
@@ -34,121 +146,332 @@ func main() {
 
 	fmt.Println("Loaded sample repos.")
 
-	repoHistory, err := getRepoHistory(client, repos)
+	historyWriter, err := github.OpenSink(options.format, "data/commits", RepoHistory{})
+	if err != nil {
+		fmt.Println("Error on opening history.")
+		panic(err)
+	}
+	defer historyWriter.Close()
+
+	var detailsWriter github.Sink
+	if options.detailed {
+		detailsWriter, err = github.OpenSink(options.format, "data/commit_details", CommitRecord{})
+		if err != nil {
+			fmt.Println("Error on opening commit details.")
+			panic(err)
+		}
+		defer detailsWriter.Close()
+	}
+
+	records, commits, err := getRepoHistory(client, repos, &options, historyWriter, detailsWriter)
+
+	fmt.Println("Number of records fetched:", records)
+	if detailsWriter != nil {
+		fmt.Println("Number of commits fetched:", commits)
+	}
 
 	if err != nil {
 		fmt.Println("Error on getting history.")
 		panic(err)
 	}
+}
+
+// getRepoHistory writes the history of every repo as soon as the repo is done,
+// and every commit when detailsWriter is given. It returns how many history
+// records and commits were written.
+func getRepoHistory(client *github.Client, repos []github.Repo, options *historyOptions, historyWriter github.Sink, detailsWriter github.Sink) (int, int, error) {
+	records, commitCount := 0, 0
+
+	since := time.Date(2016, 01, 01, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2019, 12, 31, 23, 59, 59, 9999, time.UTC)
 
-	if repoHistory != nil {
-		fmt.Println("Number of records fetched:", len(*repoHistory))
-		github.SaveToCSV(repoHistory, "data/commits.csv")
+	var queryOptions []func(*commitquery.CommitQuery)
+	if options.path != "" {
+		queryOptions = append(queryOptions, commitquery.Path(options.path))
+	}
+	if options.sha != "" {
+		queryOptions = append(queryOptions, commitquery.SHA(options.sha))
 	}
-}
 
-func readRepos(filepath string) (*[]github.Repo, error) {
-	repos := []github.Repo{}
+	for i, repo := range repos {
+		var history []RepoHistory
+		var details []CommitRecord
+		var err error
+		if options.source == "git" {
+			history, details, err = readCloneHistory(repo, since, until, options)
+		} else if options.detailed {
+			var commits []github.Commit
+			commits, err = fetchCommits(client, repo, since, until, queryOptions...)
+			if err == nil {
+				history = countCommits(repo, commits)
+				details, err = fetchCommitRecords(client, repo, commits)
+			}
+		} else if options.backend == "graphql" {
+			history, err = countRepoHistory(client, repo, since, until, options.sha, options.path)
+			if err != nil {
+				fmt.Printf("Falling back to REST for repo %s: %v\n", repo.FullName, err)
+				history, err = fetchRepoHistory(client, repo, since, until, queryOptions...)
+			}
+		} else {
+			history, err = fetchRepoHistory(client, repo, since, until, queryOptions...)
+		}
+		if err != nil {
+			return records, commitCount, err
+		}
 
-	file, err := os.Open(filepath)
-	if err != nil {
-		fmt.Println("Error opening CSV file:", err)
-		return nil, err
+		if err := github.WriteRows(historyWriter, history...); err != nil {
+			return records, commitCount, err
+		}
+		records += len(history)
+
+		if detailsWriter != nil {
+			if err := github.WriteRows(detailsWriter, details...); err != nil {
+				return records, commitCount, err
+			}
+			commitCount += len(details)
+		}
+
+		fmt.Println("Repos parsed:", i+1, "/", len(repos), "| Records added:", len(history))
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
+	return records, commitCount, nil
+}
 
-	header, err := reader.Read()
+// fetchRepoHistory pages through every commit in the window over REST and
+// counts them per interval.
+func fetchRepoHistory(client *github.Client, repo github.Repo, since time.Time, until time.Time, options ...func(*commitquery.CommitQuery)) ([]RepoHistory, error) {
+	commits, err := fetchCommits(client, repo, since, until, options...)
 	if err != nil {
-		fmt.Println("Error reading CSV header:", err)
 		return nil, err
 	}
 
-	columnIndex := make(map[string]int)
-	for i, columnName := range header {
-		columnIndex[columnName] = i
-	}
+	return countCommits(repo, commits), nil
+}
+
+func fetchCommits(client *github.Client, repo github.Repo, since time.Time, until time.Time, options ...func(*commitquery.CommitQuery)) ([]github.Commit, error) {
+	commits := []github.Commit{}
+
+	query := commitquery.NewCommitQuery(options...)
+	query.Set(
+		commitquery.Since(since),
+		commitquery.Until(until),
+		commitquery.PerPage(100),
+	)
 
-	for {
-		record, err := reader.Read()
+	for page := 1; ; page++ {
+		query.Set(commitquery.Page(page))
+
+		pageCommits, err := client.FetchCommits(repo.FullName, query)
 		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			fmt.Println("Error reading record from CSV:", err)
+			fmt.Printf("Error fetching commits for repo %s: %v\n", repo.FullName, err)
 			return nil, err
 		}
 
-		repo := github.Repo{}
-		for columnName, index := range columnIndex {
-			switch columnName {
-			case "id":
-				repo.ID, _ = strconv.Atoi(record[index])
-			case "name":
-				repo.Name = record[index]
-			case "full_name":
-				repo.FullName = record[index]
-			case "stargazers_count":
-				repo.Stars, _ = strconv.Atoi(record[index])
-			}
+		if len(pageCommits) == 0 {
+			break
 		}
 
-		repos = append(repos, repo)
+		commits = append(commits, pageCommits...)
 	}
 
-	return &repos, nil
+	return commits, nil
 }
 
-func getRepoHistory(client *github.Client, repos *[]github.Repo) (*[]RepoHistory, error) {
-	dataset := []RepoHistory{}
+func countCommits(repo github.Repo, commits []github.Commit) []RepoHistory {
+	dates := make([]time.Time, len(commits))
+	for i, commit := range commits {
+		dates[i] = commitDate(&commit)
+	}
 
-	since := time.Date(2016, 01, 01, 0, 0, 0, 0, time.UTC)
-	until := time.Date(2019, 12, 31, 23, 59, 59, 9999, time.UTC)
+	return countDates(repo, dates)
+}
 
-	addedRecords := 0
-	for i, repo := range *repos {
-		addedRecords = len(dataset)
-		per_page := 100
+func countDates(repo github.Repo, dates []time.Time) []RepoHistory {
+	intervalData := make(map[int]*RepoHistory)
 
-		intervalData := make(map[int]*RepoHistory)
+	for _, date := range dates {
+		interval := dateToInterval(date)
+		if _, ok := intervalData[interval]; !ok {
+			intervalData[interval] = &RepoHistory{RepoID: repo.ID, Interval: interval}
+		}
+		intervalData[interval].Commits++
+	}
 
-		for page := 1; ; page++ {
-			commits, err := client.FetchAllCommitsForRepo(repo.FullName, since, until, per_page, page)
-			if err != nil {
-				fmt.Printf("Error fetching commits for repo %s: %v\n", repo.FullName, err)
-				return nil, err
-			}
+	history := []RepoHistory{}
+	for _, data := range intervalData {
+		history = append(history, *data)
+	}
 
-			if len(commits) == 0 {
-				break
-			}
+	return history
+}
 
-			for _, commit := range commits {
-				var commitDate time.Time
+// commitDate is the date a commit is counted in. It is the committer date, as
+// the since and until of REST, git log and GraphQL history all filter on it,
+// so every backend puts a commit in the same interval.
+func commitDate(commit *github.Commit) time.Time {
+	if commit.Commit.Committer.Date.IsZero() {
+		return commit.Commit.Author.Date
+	}
+	return commit.Commit.Committer.Date
+}
 
-				if commit.Commit.Author.Date.Year() == 0 {
-					commitDate = commit.Commit.Commiter.Date
-				} else {
-					commitDate = commit.Commit.Author.Date
-				}
+// fetchCommitRecords fetches every commit on its own, as only the single
+// commit endpoint returns additions, deletions and changed files.
+func fetchCommitRecords(client *github.Client, repo github.Repo, commits []github.Commit) ([]CommitRecord, error) {
+	records := []CommitRecord{}
 
-				interval := dateToInterval(commitDate)
-				if _, ok := intervalData[interval]; !ok {
-					intervalData[interval] = &RepoHistory{RepoID: repo.ID, Interval: interval}
-				}
-				intervalData[interval].Commits++
-			}
+	for _, listed := range commits {
+		commit, err := client.FetchCommit(repo.FullName, listed.SHA)
+		if err != nil {
+			fmt.Printf("Error fetching commit %s for repo %s: %v\n", listed.SHA, repo.FullName, err)
+			return nil, err
+		}
+
+		records = append(records, toCommitRecord(repo, commit))
+	}
+
+	return records, nil
+}
+
+func toCommitRecord(repo github.Repo, commit *github.Commit) CommitRecord {
+	record := CommitRecord{
+		RepoID:             repo.ID,
+		SHA:                commit.SHA,
+		Interval:           dateToInterval(commitDate(commit)),
+		AuthorName:         commit.Commit.Author.Name,
+		AuthorEmail:        commit.Commit.Author.Email,
+		AuthorDate:         commit.Commit.Author.Date,
+		CommitterName:      commit.Commit.Committer.Name,
+		CommitterEmail:     commit.Commit.Committer.Email,
+		CommitterDate:      commit.Commit.Committer.Date,
+		Message:            commit.Commit.Message,
+		Verified:           commit.Commit.Verification.Verified,
+		VerificationReason: commit.Commit.Verification.Reason,
+		FilesChanged:       len(commit.Files),
+		FilesTruncated:     commit.FilesTruncated,
+	}
+
+	if commit.FilesTruncated {
+		fmt.Printf("[WARNING] commit %s of repo %s has more files than the API lists, files_changed is a lower bound\n", commit.SHA, repo.FullName)
+	}
+
+	if commit.Author != nil {
+		record.AuthorLogin = commit.Author.Login
+	}
+	if commit.Committer != nil {
+		record.CommitterLogin = commit.Committer.Login
+	}
+
+	parents := make([]string, len(commit.Parents))
+	for i, parent := range commit.Parents {
+		parents[i] = parent.SHA
+	}
+	record.Parents = strings.Join(parents, " ")
+
+	if commit.Stats != nil {
+		record.Additions = commit.Stats.Additions
+		record.Deletions = commit.Stats.Deletions
+	}
+
+	return record
+}
+
+// readCloneHistory reads the commits in the window from a bare clone on disk
+// instead of the API, yielding the same rows without using any quota.
+func readCloneHistory(repo github.Repo, since time.Time, until time.Time, options *historyOptions) ([]RepoHistory, []CommitRecord, error) {
+	query := gitlog.NewLogQuery(
+		gitlog.Since(since),
+		gitlog.Until(until),
+		gitlog.Mailmap(options.mailmap),
+		gitlog.NoMerges(options.noMerges),
+		gitlog.VerifySignatures(options.detailed),
+	)
+	if options.sha != "" {
+		query.Set(gitlog.Ref(options.sha))
+	}
+	if options.path != "" {
+		query.Set(gitlog.Path(options.path))
+	}
+
+	commits, err := gitlog.Log(filepath.Join(options.clones, repo.FullName+".git"), query)
+	if err != nil {
+		fmt.Printf("Error reading clone of repo %s: %v\n", repo.FullName, err)
+		return nil, nil, err
+	}
+
+	dates := make([]time.Time, len(commits))
+	records := make([]CommitRecord, len(commits))
+	for i, commit := range commits {
+		dates[i] = commit.CommitterDate
+		if dates[i].IsZero() {
+			dates[i] = commit.AuthorDate
 		}
 
-		for _, data := range intervalData {
-			dataset = append(dataset, *data)
+		records[i] = CommitRecord{
+			RepoID:         repo.ID,
+			SHA:            commit.SHA,
+			Interval:       dateToInterval(dates[i]),
+			AuthorName:     commit.AuthorName,
+			AuthorEmail:    commit.AuthorEmail,
+			AuthorDate:     commit.AuthorDate,
+			CommitterName:  commit.CommitterName,
+			CommitterEmail: commit.CommitterEmail,
+			CommitterDate:  commit.CommitterDate,
+			Message:        commit.Message,
+			Parents:        strings.Join(commit.Parents, " "),
+			Verified:       commit.Signature == "G",
+			Additions:      commit.Additions,
+			Deletions:      commit.Deletions,
+			FilesChanged:   commit.FilesChanged,
 		}
+	}
+
+	return countDates(repo, dates), records, nil
+}
+
+// countRepoHistory asks GraphQL for the number of commits in each interval,
+// yielding the same rows as fetchRepoHistory without downloading commits.
+func countRepoHistory(client *github.Client, repo github.Repo, since time.Time, until time.Time, sha string, path string) ([]RepoHistory, error) {
+	intervals, numbers := splitIntervals(since, until)
 
-		fmt.Println("Repos parsed:", i+1, "/", len(*repos), "| Records added:", len(dataset)-addedRecords)
+	counts, err := client.FetchCommitCounts(repo.FullName, sha, path, intervals)
+	if err != nil {
+		return nil, err
 	}
 
-	return &dataset, nil
+	history := []RepoHistory{}
+	for i, count := range counts {
+		if count > 0 {
+			history = append(history, RepoHistory{RepoID: repo.ID, Commits: count, Interval: numbers[i]})
+		}
+	}
+
+	return history, nil
+}
+
+// splitIntervals cuts the window wherever dateToInterval changes, so each
+// piece holds exactly the commits one interval number is given.
+func splitIntervals(since time.Time, until time.Time) ([]github.Interval, []int) {
+	var intervals []github.Interval
+	var numbers []int
+
+	start := since
+	for start.Before(until) {
+		number := dateToInterval(start)
+
+		end := start
+		for step := time.Duration(1<<20) * time.Second; step >= time.Second; step /= 2 {
+			for end.Add(step).Before(until) && dateToInterval(end.Add(step)) == number {
+				end = end.Add(step)
+			}
+		}
+
+		intervals = append(intervals, github.Interval{Since: start, Until: end})
+		numbers = append(numbers, number)
+		start = end.Add(time.Second)
+	}
+
+	return intervals, numbers
 }
 
 func dateToInterval(date time.Time) int {
@@ -156,3 +479,9 @@ func dateToInterval(date time.Time) int {
 	weeks := int(date.Sub(startOfYear).Hours()/24/7) + 1
 	return weeks
 }
+
+// intervalStart is the first day of an interval numbered by dateToInterval.
+func intervalStart(interval int) time.Time {
+	startOfYear := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return startOfYear.AddDate(0, 0, 7*(interval-1))
+}
@@ -1,15 +1,86 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github-issue-data/pkg"
 	"github-issue-data/pkg/repos"
 )
 
+// API returns incorrect max if min is less than 20
+const minStars = 100
+
+// GitHub launched in 2008, so nothing was created before then
+var launched = time.Date(2008, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// RepoRecord is a row of repos.csv, a repo with the searches that matched it.
+type RepoRecord struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	FullName   string `json:"full_name"`
+	Stars      int    `json:"stargazers_count"`
+	Matched    string `json:"matched_queries"`
+	Population string `json:"population"`
+}
+
+// HistoricalRepoRecord is a row of repos.csv when the population is rebuilt as
+// of a reference date, with the state the inclusion criteria were applied to.
+type HistoricalRepoRecord struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	FullName     string `json:"full_name"`
+	Stars        int    `json:"stargazers_count"`
+	Matched      string `json:"matched_queries"`
+	Population   string `json:"population"`
+	AsOf         string `json:"as_of"`
+	StarsAsOf    int    `json:"stars_as_of"`
+	LastPushAsOf string `json:"last_push_as_of"`
+}
+
+func (record RepoRecord) SQLiteUpserts() []github.Upsert {
+	return []github.Upsert{{
+		Table: "repos",
+		Key:   []string{"id"},
+		Values: map[string]any{
+			"id":               record.ID,
+			"name":             record.Name,
+			"full_name":        record.FullName,
+			"stargazers_count": record.Stars,
+			"matched_queries":  record.Matched,
+			"population":       record.Population,
+		},
+	}}
+}
+
+func (record HistoricalRepoRecord) SQLiteUpserts() []github.Upsert {
+	return []github.Upsert{{
+		Table: "repos",
+		Key:   []string{"id"},
+		Values: map[string]any{
+			"id":               record.ID,
+			"name":             record.Name,
+			"full_name":        record.FullName,
+			"stargazers_count": record.Stars,
+			"matched_queries":  record.Matched,
+			"population":       record.Population,
+			"as_of":            record.AsOf,
+			"stars_as_of":      record.StarsAsOf,
+			"last_push_as_of":  record.LastPushAsOf,
+		},
+	}}
+}
+
 func main() {
+	complete := flag.Bool("complete", false, "retry incomplete search pages and narrow partitions until they are complete")
+	asOf := flag.String("as-of", "", "rebuild the population as it was on this date (YYYY-MM-DD) instead of today")
+	candidateStars := flag.Int("candidate-stars", minStars/2, "with -as-of, minimum stars today for a repo to be considered")
+	format := flag.String("format", github.DefaultFormat(), github.FormatUsage())
+	flag.Parse()
+
 	token := os.Getenv("GITHUB_TOKEN")
 
 	if token == "" {
@@ -18,111 +89,239 @@ func main() {
 
 	client := github.NewClient(token)
 
-	repos, err := getRepos(client)
+	maxRetries := 0
+	if *complete {
+		maxRetries = 5
+	}
+
+	if *asOf != "" {
+		at, err := time.Parse("2006-01-02", *asOf)
+		if err != nil {
+			fmt.Println("Invalid -as-of date:", err)
+			os.Exit(1)
+		}
+
+		writer, err := github.OpenInputSink(*format, "data/repos", HistoricalRepoRecord{})
+		if err != nil {
+			fmt.Println("Error on opening repos.\n[ERROR] -", err)
+			panic(err)
+		}
+		defer writer.Close()
+
+		manifestWriter, err := github.OpenSink(*format, "data/repos_manifest", github.PartitionReport{})
+		if err != nil {
+			fmt.Println("Error on opening manifest.\n[ERROR] -", err)
+			panic(err)
+		}
+		defer manifestWriter.Close()
+
+		if err := getHistoricalRepos(client, maxRetries, at, *candidateStars, writer, manifestWriter); err != nil {
+			fmt.Println("Error on getting batch.\n[ERROR] -", err)
+			panic(err)
+		}
+		return
+	}
+
+	writer, err := github.OpenInputSink(*format, "data/repos", RepoRecord{})
+	if err != nil {
+		fmt.Println("Error on opening repos.\n[ERROR] -", err)
+		panic(err)
+	}
+	defer writer.Close()
+
+	manifestWriter, err := github.OpenSink(*format, "data/repos_manifest", github.PartitionReport{})
 	if err != nil {
+		fmt.Println("Error on opening manifest.\n[ERROR] -", err)
+		panic(err)
+	}
+	defer manifestWriter.Close()
+
+	if err := getRepos(client, maxRetries, writer, manifestWriter); err != nil {
 		fmt.Println("Error on getting batch.\n[ERROR] -", err)
 		panic(err)
 	}
-	github.SaveToCSV(repos, "data/repos.csv")
 }
 
-func getRepos(client *github.Client) (*[]github.Repo, error) {
-	defaultSearchParams := getSearchFilter()
+func getRepos(client *github.Client, maxRetries int, writer github.Sink, manifestWriter github.Sink) error {
+	created, pushed := getStudyDates()
+	population := getPopulation(func(query string) *repos.SearchParams {
+		return getSearchFilter(query, created, pushed)
+	})
 
-	populationSize, err := getPopulationSize(client, defaultSearchParams.Copy())
+	splits := getSplits(client, minStars, created, pushed)
+
+	members, manifest, err := client.FetchPopulation(population, maxRetries, splits)
 	if err != nil {
-		fmt.Println("Error on getting population size.\n[ERROR] -", err)
-		panic(err)
+		return err
 	}
 
-	populationIds := make(map[int]bool)
-	population := make([]github.Repo, populationSize)
+	warnIncomplete(manifest)
 
-	perPage := 100
-	minStars := 100
-	defaultSearchParams.Set(repos.Stars(repos.Int{}.Min(minStars)))
+	if err := github.WriteRows(manifestWriter, manifest...); err != nil {
+		return err
+	}
 
-	then := time.Now()
-	for page, index := 0, 0; index < populationSize; page++ {
-		if page == 10 {
-			page = 0
-			minStars = population[index-1].Stars
-			defaultSearchParams.Set(repos.Stars(repos.Int{}.Min(minStars)))
-			fmt.Println("Progress: ", index+1, "/", populationSize)
-			fmt.Println("minStars: ", minStars)
+	records := make([]RepoRecord, len(members))
+	for i, member := range members {
+		records[i] = RepoRecord{
+			ID:         member.ID,
+			Name:       member.Name,
+			FullName:   member.FullName,
+			Stars:      member.Stars,
+			Matched:    strings.Join(member.Matched, ";"),
+			Population: population.String(),
 		}
+	}
 
-		now := time.Now()
-		diff := now.Sub(then)
-		if diff.Seconds() < 2 {
-			time.Sleep(diff)
-		}
+	return github.WriteRows(writer, records...)
+}
 
-		repos, _, incomplete, err := client.FetchRepos(repos.NewFetchReposParams(
-			repos.SetSearchParams(defaultSearchParams),
-			repos.SetPage(page),
-			repos.SetPerPage(perPage),
-			repos.SetSort(repos.SortByStars()),
-			repos.SetOrder(repos.Asc()),
-		))
+// getHistoricalRepos rebuilds the population as it was at the given date to
+// avoid survivorship bias. Candidates are searched with relaxed criteria, as
+// today's stars and push dates say little about the past, and a candidate is
+// kept if at that date it existed, had at least minStars stars and had been
+// pushed to in the six months before. Records are written as candidates are
+// checked.
+func getHistoricalRepos(client *github.Client, maxRetries int, at time.Time, candidateStars int, writer github.Sink, manifestWriter github.Sink) error {
+	population := getPopulation(func(query string) *repos.SearchParams {
+		return getCandidateFilter(query, at, candidateStars)
+	})
+
+	splits := getSplits(client, candidateStars, at, launched)
+
+	candidates, manifest, err := client.FetchPopulation(population, maxRetries, splits)
+	if err != nil {
+		return err
+	}
+
+	warnIncomplete(manifest)
+
+	if err := github.WriteRows(manifestWriter, manifest...); err != nil {
+		return err
+	}
+
+	activeSince := at.AddDate(0, -6, 0)
+
+	included := 0
+	for i, candidate := range candidates {
+		state, err := client.FetchRepoStateAt(candidate.FullName, at)
 		if err != nil {
-			fmt.Println("Error on fetching batch.\n[ERROR] -", err)
-			return nil, err
+			return err
 		}
-		then = now
 
-		if incomplete {
-			fmt.Println("[WARNING] incomplete page.")
+		if state.Existed && state.Stars >= minStars && !state.LastPushAt.Before(activeSince) {
+			err := github.WriteRows(writer, HistoricalRepoRecord{
+				ID:           candidate.ID,
+				Name:         candidate.Name,
+				FullName:     candidate.FullName,
+				Stars:        candidate.Stars,
+				Matched:      strings.Join(candidate.Matched, ";"),
+				Population:   population.String(),
+				AsOf:         at.Format("2006-01-02"),
+				StarsAsOf:    state.Stars,
+				LastPushAsOf: state.LastPushAt.Format(time.RFC3339),
+			})
+			if err != nil {
+				return err
+			}
+			included++
 		}
 
-		for _, repo := range repos {
-			if populationIds[repo.ID] {
-				continue
-			}
+		fmt.Println("Candidates checked:", i+1, "/", len(candidates), "| Included:", included)
+	}
+
+	return nil
+}
 
-			population[index] = repo
-			populationIds[repo.ID] = true
-			index++
+func getSplits(client *github.Client, starsFloor int, created time.Time, pushed time.Time) func(*repos.SearchParams) ([]repos.Split, error) {
+	return func(searchParams *repos.SearchParams) ([]repos.Split, error) {
+		maxStars, err := getMaxStars(client, searchParams.Copy(), starsFloor)
+		if err != nil {
+			fmt.Println("Error on getting max stars.\n[ERROR] -", err)
+			return nil, err
 		}
-		break
-	}
 
-	fmt.Println("Progress: ", populationSize, "/", populationSize)
+		return []repos.Split{
+			repos.StarsSplit(starsFloor, maxStars),
+			repos.CreatedSplit(launched, created),
+			repos.PushedSplit(pushed, time.Now().UTC()),
+		}, nil
+	}
+}
 
-	return &population, nil
+func warnIncomplete(manifest []github.PartitionReport) {
+	incomplete := 0
+	for _, report := range manifest {
+		if !report.Complete {
+			incomplete++
+		}
+	}
+	if incomplete > 0 {
+		fmt.Println("[WARNING]", incomplete, "/", len(manifest), "partitions incomplete, see data/repos_manifest.csv")
+	}
 }
 
-func getSearchFilter() *repos.SearchParams {
+func getStudyDates() (time.Time, time.Time) {
 	created, _ := time.Parse("2006-01-02", "2019-09-30")
 	pushed := created.AddDate(0, 6, 0)
+	return created, pushed
+}
+
+// getPopulation defines the study population. Further searches can be combined
+// with Union, Intersect and Minus, e.g.
+//
+//	library.Union(repos.Match("framework", framework)).Minus(repos.Match("forks", forks))
+func getPopulation(filter func(query string) *repos.SearchParams) *repos.Population {
+	library := repos.Match("library", filter("library"))
+
+	return library
+}
+
+func getSearchFilter(query string, created time.Time, pushed time.Time) *repos.SearchParams {
 	searchFilter := repos.NewSearchParams(
-		repos.Query("library"),
+		repos.Query(query),
 		repos.Created(repos.Time{}.Max(created)),
 		repos.Is(repos.Public()),
 		repos.Mirror(false),
 		repos.Template(false),
-		// API returns incorrect max if min is less than 20
-		repos.Stars(repos.Int{}.Min(100)),
+		repos.Stars(repos.Int{}.Min(minStars)),
 		repos.Pushed(repos.Time{}.Min(pushed)),
 	)
 
 	return searchFilter
 }
 
-func getPopulationSize(client *github.Client, searchParams *repos.SearchParams) (int, error) {
-	_, populationSize, _, err := client.FetchRepos(repos.NewFetchReposParams(
+// getCandidateFilter matches every repo that could have passed the filter at
+// the given date.
+func getCandidateFilter(query string, at time.Time, candidateStars int) *repos.SearchParams {
+	searchFilter := repos.NewSearchParams(
+		repos.Query(query),
+		repos.Created(repos.Time{}.Max(at)),
+		repos.Is(repos.Public()),
+		repos.Mirror(false),
+		repos.Template(false),
+		repos.Stars(repos.Int{}.Min(candidateStars)),
+	)
+
+	return searchFilter
+}
+
+func getMaxStars(client *github.Client, searchParams *repos.SearchParams, starsFloor int) (int, error) {
+	items, _, _, err := client.FetchRepos(repos.NewFetchReposParams(
 		repos.SetSearchParams(searchParams),
 		repos.SetPage(1),
 		repos.SetPerPage(1),
 		repos.SetSort(repos.SortByStars()),
+		repos.SetOrder(repos.Desc()),
 	))
 
 	if err != nil {
-		fmt.Println("Error on getting population size.\n[ERROR] -", err)
 		return 0, err
 	}
 
-	fmt.Println("population size:", populationSize)
+	if len(items) == 0 {
+		return starsFloor, nil
+	}
 
-	return populationSize, nil
+	return items[0].Stars, nil
 }
@@ -1,66 +1,86 @@
 package main
 
 import (
-	"encoding/csv"
+	"flag"
+	"fmt"
 	"math/rand/v2"
-	"os"
 	"sort"
+
+	"github-issue-data/pkg"
 )
 
 const SAMPLE_SIZE = 100
 
+// SampleRecord is a row of repos.csv, with the query and population it was
+// found by.
+type SampleRecord struct {
+	ID         int    `json:"id" csv:",required"`
+	Name       string `json:"name"`
+	FullName   string `json:"full_name" csv:",required"`
+	Stars      int    `json:"stargazers_count"`
+	Matched    string `json:"matched_queries"`
+	Population string `json:"population"`
+}
+
+func (record SampleRecord) SQLiteUpserts() []github.Upsert {
+	return []github.Upsert{
+		{
+			Table: "repos",
+			Key:   []string{"id"},
+			Values: map[string]any{
+				"id":               record.ID,
+				"name":             record.Name,
+				"full_name":        record.FullName,
+				"stargazers_count": record.Stars,
+				"matched_queries":  record.Matched,
+				"population":       record.Population,
+			},
+		},
+		{Table: "samples", Key: []string{"repo_id"}, Values: map[string]any{"repo_id": record.ID}},
+	}
+}
+
 func main() {
+	format := flag.String("format", github.DefaultFormat(), github.FormatUsage())
+	flag.Parse()
+
 	reposFilepath := "data/repos.csv"
 
-	randomSample(reposFilepath, SAMPLE_SIZE, "data/sample.csv")
+	if err := randomSample(reposFilepath, SAMPLE_SIZE, *format, "data/sample"); err != nil {
+		fmt.Println("Error sampling repos.")
+		panic(err)
+	}
 }
 
-func randomSample(reposFilepath string, sampleSize int, outputFile string) error {
+func randomSample(reposFilepath string, sampleSize int, format string, outputPath string) error {
 	pcg := rand.NewPCG(123, 420)
 
-	file, err := os.Open(reposFilepath)
+	repos, err := github.LoadFromCSV[SampleRecord](reposFilepath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	allRecords, err := reader.ReadAll()
-	if err != nil {
-		return err
+	if sampleSize > len(repos) {
+		sampleSize = len(repos)
 	}
 
-	header := allRecords[0]
-	allRecords = allRecords[1:]
+	indices := *getIndices(sampleSize, len(repos), pcg)
 
-	if sampleSize > len(allRecords) {
-		sampleSize = len(allRecords)
+	sampled := make([]SampleRecord, 0, len(indices))
+	for _, index := range indices {
+		sampled = append(sampled, repos[index])
 	}
 
-	indices := *getIndices(sampleSize, len(allRecords), pcg)
-
-	output, err := os.Create(outputFile)
+	sink, err := github.OpenInputSink(format, outputPath, SampleRecord{})
 	if err != nil {
 		return err
 	}
-	defer output.Close()
 
-	writer := csv.NewWriter(output)
-	defer writer.Flush()
-
-	// Write header
-	if err := writer.Write(header); err != nil {
+	if err := github.WriteRows(sink, sampled...); err != nil {
+		sink.Close()
 		return err
 	}
-
-	// Write sampled rows
-	for _, index := range indices {
-		if err := writer.Write(allRecords[index]); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return sink.Close()
 }
 
 func getIndices(sampleSize int, populationSize int, seed *rand.PCG) *[]int {
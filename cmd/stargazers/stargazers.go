@@ -1,34 +1,60 @@
 package main
 
 import (
-	"context"
-	"encoding/csv"
 	"errors"
+	"flag"
 	"fmt"
+	"math"
 	"os"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 
 	"github-issue-data/pkg"
-
-	"github.com/machinebox/graphql"
 )
 
-type StarHistory struct {
-	RepoID   int `json:"repo_id"`
-	Stars    int `json:"stars"`
-	Interval int `json:"interval"`
-}
+func main() {
+	backend := flag.String("backend", "auto", "collector backend, rest, graphql, or auto for graphql with a fallback to rest")
+	estimateAbove := flag.Int("estimate-above", 40000, "estimate the history of repos with more stars than this from sampled pages, 0 to only estimate repos REST cannot list in full")
+	samplePages := flag.Int("sample-pages", 50, "number of stargazer pages sampled when estimating")
+	events := flag.Bool("events", false, "write every star with its user to data/star_events.csv instead of the weekly history")
+	fromEvents := flag.Bool("from-events", false, "aggregate data/star_events.csv into the weekly history without fetching")
+	format := flag.String("format", github.DefaultFormat(), github.FormatUsage())
+	flag.Parse()
+
+	if *backend != "auto" && *backend != "rest" && *backend != "graphql" {
+		fmt.Println("Unknown backend:", *backend)
+		os.Exit(1)
+	}
 
-type stargazer struct {
-	StarredAt time.Time
-}
+	if *fromEvents {
+		starEvents, err := github.LoadStarEvents("data/star_events.csv")
+		if err != nil {
+			fmt.Println("Error reading star events.")
+			panic(err)
+		}
+
+		writer, err := github.OpenSink(*format, "data/stargazers", github.StarHistory{})
+		if err != nil {
+			fmt.Println("Error opening stargazers.")
+			panic(err)
+		}
+		defer writer.Close()
+
+		if err := github.WriteRows(writer, github.AggregateStarEvents(starEvents)...); err != nil {
+			fmt.Println("Error writing stargazers.")
+			panic(err)
+		}
+		return
+	}
+
+	token := os.Getenv("GITHUB_TOKEN")
+
+	if token == "" {
+		fmt.Println("Please set the GITHUB_TOKEN environment variable.")
+	}
 
-func main() {
 	reposFilepath := "data/sample.csv"
-	repos, err := readRepos(reposFilepath)
+	repos, err := github.LoadFromCSV[github.Repo](reposFilepath)
 	if err != nil {
 		fmt.Println("Error fetching repos.")
 		panic(err)
@@ -36,201 +62,178 @@ func main() {
 
 	fmt.Println("Loaded sample repos.")
 
-	client := graphql.NewClient("https://api.github.com/graphql")
+	client := github.NewClient(token)
 
 	fmt.Println("Fetching stargazers.")
 
-	var allStargazers []StarHistory
-	historyParsed := 0
-	for i, repo := range *repos {
-		historyParsed = len(allStargazers)
-		stargazers, err := fetchStargazers(client, repo)
+	if *events {
+		writer, err := github.OpenInputSink(*format, "data/star_events", github.StarEvent{})
 		if err != nil {
-			fmt.Println("Error fetching stargazers.", err)
+			fmt.Println("Error opening star events.")
+			panic(err)
 		}
-		allStargazers = append(allStargazers, stargazers...)
-		fmt.Println("Repos parsed:", i+1, "/", len(*repos), "| Records added:", len(allStargazers)-historyParsed)
-	}
+		defer writer.Close()
 
-	github.SaveToCSV(&allStargazers, "data/stargazers.csv")
-}
+		for i, repo := range repos {
+			// only GraphQL lists every stargazer of a repo this large
+			if *backend == "rest" && repo.Stars > github.MaxRESTStargazers {
+				fmt.Println("[WARNING] skipping", repo.FullName, "as REST cannot list all of its stargazers, use -backend graphql or auto.")
+				continue
+			}
 
-func readRepos(filepath string) (*[]github.Repo, error) {
-	repos := []github.Repo{}
+			starEvents, err := fetchStarEvents(client, repo, *backend)
+			if errors.Is(err, github.ErrStargazerPageCap) {
+				fmt.Println("[WARNING] skipping", repo.FullName, "as REST cannot list all of its stargazers.")
+			} else if err != nil {
+				fmt.Println("Error fetching stargazers.", err)
+			}
+			if err := github.WriteRows(writer, starEvents...); err != nil {
+				fmt.Println("Error writing star events.")
+				panic(err)
+			}
+			fmt.Println("Repos parsed:", i+1, "/", len(repos), "| Records added:", len(starEvents))
+		}
+		return
+	}
 
-	file, err := os.Open(filepath)
+	writer, err := github.OpenSink(*format, "data/stargazers", github.StarHistory{})
 	if err != nil {
-		fmt.Println("Error opening CSV file:", err)
-		return nil, err
+		fmt.Println("Error opening stargazers.")
+		panic(err)
 	}
-	defer file.Close()
+	defer writer.Close()
+
+	for i, repo := range repos {
+		var stargazers []github.StarHistory
+		if *estimateAbove > 0 && repo.Stars > *estimateAbove {
+			stargazers, err = estimateStargazers(client, repo, *samplePages)
+		} else {
+			stargazers, err = fetchStargazers(client, repo, *backend, *samplePages)
+		}
+		if err != nil {
+			fmt.Println("Error fetching stargazers.", err)
+		}
+		if err := github.WriteRows(writer, stargazers...); err != nil {
+			fmt.Println("Error writing stargazers.")
+			panic(err)
+		}
+		fmt.Println("Repos parsed:", i+1, "/", len(repos), "| Records added:", len(stargazers))
+	}
+}
 
-	reader := csv.NewReader(file)
+// fetchStarEvents fetches every stargazer of a repo, oldest first.
+func fetchStarEvents(client *github.Client, repo github.Repo, backend string) ([]github.StarEvent, error) {
+	var stars []github.Star
+	var err error
 
-	header, err := reader.Read()
+	switch backend {
+	case "rest":
+		stars, err = client.FetchAllStargazers(repo.FullName)
+	case "graphql":
+		stars, err = client.FetchAllStargazersGraphQL(repo.FullName)
+	default:
+		stars, err = client.FetchAllStargazersGraphQL(repo.FullName)
+		if err != nil {
+			fmt.Printf("Falling back to REST for repo %s: %v\n", repo.FullName, err)
+			stars, err = client.FetchAllStargazers(repo.FullName)
+		}
+	}
 	if err != nil {
-		fmt.Println("Error reading CSV header:", err)
+		fmt.Println("Failed to fetch stargazers:", err)
 		return nil, err
 	}
 
-	columnIndex := make(map[string]int)
-	for i, columnName := range header {
-		columnIndex[columnName] = i
+	if len(stars) == 0 {
+		return nil, errors.New("no stargazers found")
 	}
 
-	for {
-		record, err := reader.Read()
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			fmt.Println("Error reading record from CSV:", err)
-			return nil, err
+	starEvents := make([]github.StarEvent, len(stars))
+	for i, star := range stars {
+		starEvents[i] = github.StarEvent{
+			RepoID:    repo.ID,
+			UserID:    star.User.ID,
+			UserLogin: star.User.Login,
+			StarredAt: star.StarredAt,
 		}
+	}
 
-		repo := github.Repo{}
-		for columnName, index := range columnIndex {
-			switch columnName {
-			case "id":
-				repo.ID, _ = strconv.Atoi(record[index])
-			case "name":
-				repo.Name = record[index]
-			case "full_name":
-				repo.FullName = record[index]
-			case "stargazers_count":
-				repo.Stars, _ = strconv.Atoi(record[index])
-			}
-		}
+	sort.SliceStable(starEvents, func(i, j int) bool {
+		return starEvents[i].StarredAt.Before(starEvents[j].StarredAt)
+	})
 
-		repos = append(repos, repo)
+	return starEvents, nil
+}
+
+// fetchStargazers aggregates every star of a repo into its weekly history,
+// estimating it instead when REST cannot list every stargazer.
+func fetchStargazers(client *github.Client, repo github.Repo, backend string, samplePages int) ([]github.StarHistory, error) {
+	starEvents, err := fetchStarEvents(client, repo, backend)
+	if errors.Is(err, github.ErrStargazerPageCap) {
+		fmt.Println("Estimating the star history of", repo.FullName, "as REST cannot list all of its stargazers.")
+		return estimateStargazers(client, repo, samplePages)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &repos, nil
+	return github.AggregateStarEvents(starEvents), nil
 }
 
-func fetchStargazers(client *graphql.Client, repo github.Repo) ([]StarHistory, error) {
-	maxRetries := 50
-	req := graphql.NewRequest(`
-        query ($owner: String!, $name: String!, $cursor: String) {
-            repository(owner: $owner, name: $name) {
-                stargazers(first: 100, after: $cursor, orderBy: {field: STARRED_AT, direction: ASC}) {
-                    edges {
-                        starredAt
-                        node {
-                            login
-                        }
-                    }
-                    pageInfo {
-                        endCursor
-                        hasNextPage
-                    }
-                }
-            }
-        }
-	`)
-
-	owner := strings.Split(repo.FullName, "/")[0]
-	req.Var("owner", owner)
-	req.Var("name", repo.Name)
-	req.Var("cursor", nil)
-
-	if os.Getenv("GITHUB_TOKEN") == "" {
-		fmt.Println("Please set GITHUB_TOKEN environment variable.")
-		return nil, errors.New("missing GITHUB_TOKEN")
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", os.Getenv("GITHUB_TOKEN")))
-
-	var respData struct {
-		Repository struct {
-			Stargazers struct {
-				Edges []struct {
-					StarredAt time.Time `json:"starredAt"`
-					Node      struct {
-						Login string `json:"login"`
-					} `json:"node"`
-				} `json:"edges"`
-				PageInfo struct {
-					EndCursor   string `json:"endCursor"`
-					HasNextPage bool   `json:"hasNextPage"`
-				} `json:"pageInfo"`
-			} `json:"stargazers"`
-		} `json:"repository"`
-	}
-
-	stargazers := []stargazer{}
-
-	cursor := ""
-	for {
-		req.Var("cursor", cursor)
-		if err := fetchWithRetry(client, req, &respData, maxRetries); err != nil {
-			fmt.Println("Failed to fetch stargazers after retries:", err)
-			return nil, err
-		}
-		for _, edge := range respData.Repository.Stargazers.Edges {
-			stargazers = append(stargazers, stargazer{StarredAt: edge.StarredAt})
-		}
-		if !respData.Repository.Stargazers.PageInfo.HasNextPage {
-			break
-		}
-		cursor = respData.Repository.Stargazers.PageInfo.EndCursor
+// estimateStargazers interpolates the weekly star history of a repo too large
+// to page through from evenly spaced stargazer pages.
+func estimateStargazers(client *github.Client, repo github.Repo, samplePages int) ([]github.StarHistory, error) {
+	current, err := client.FetchRepo(repo.FullName)
+	if err != nil {
+		fmt.Println("Failed to fetch repo:", err)
+		return nil, err
 	}
 
-	if len(stargazers) == 0 {
+	samples, err := client.SampleStargazers(repo.FullName, current.Stars, samplePages)
+	if err != nil {
+		fmt.Println("Failed to sample stargazers:", err)
+		return nil, err
+	}
+
+	if len(samples.Samples) == 0 {
 		return nil, errors.New("no stargazers found")
 	}
 
-	sort.Slice(stargazers, func(i, j int) bool {
-		return stargazers[i].StarredAt.Before(stargazers[j].StarredAt)
-	})
+	start := github.StarsSince
+	end := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
 
-	var stargazerHistories []StarHistory
+	// stars from before 2016 are not counted, as with the exact history
+	before, beforeMin, beforeMax := samples.CountAt(start)
 
-	lastInterval := 0
-	totalStars := 0
-	for _, sg := range stargazers {
-		if sg.StarredAt.Year() < 2016 {
-			continue
-		} else if sg.StarredAt.Year() > 2019 {
-			break
+	var stargazerHistories []github.StarHistory
+	for interval := 1; ; interval++ {
+		until := start.AddDate(0, 0, 7*interval)
+		if until.After(end) {
+			until = end
 		}
 
-		interval := dateToInterval(sg.StarredAt)
-		if interval != lastInterval {
-			// skip if no stars accumulated yet
-			if totalStars > 0 {
-				stargazerHistories = append(stargazerHistories, StarHistory{
-					RepoID:   repo.ID,
-					Stars:    totalStars,
-					Interval: lastInterval,
-				})
-			}
-			lastInterval = interval
+		stars, starsMin, starsMax := samples.CountAt(until)
+		starsMin -= beforeMax
+		starsMax -= beforeMin
+		if starsMin < 0 {
+			starsMin = 0
 		}
-		totalStars++
-	}
 
-	return stargazerHistories, nil
-}
+		// skip if no stars accumulated yet
+		if starsMax > 0 {
+			stargazerHistories = append(stargazerHistories, github.StarHistory{
+				RepoID:    repo.ID,
+				Stars:     int(math.Round(stars - before)),
+				Interval:  interval,
+				Estimated: starsMin != starsMax,
+				StarsMin:  starsMin,
+				StarsMax:  starsMax,
+			})
+		}
 
-func fetchWithRetry(client *graphql.Client, req *graphql.Request, respData interface{}, maxRetries int) error {
-	var err error
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err = client.Run(context.Background(), req, respData)
-		if err != nil {
-			fmt.Printf("Attempt %d: request failed: %s\n", attempt, err)
-			backoffDuration := time.Duration(attempt^2) * time.Second
-			fmt.Printf("Waiting for %s before retrying...\n", backoffDuration)
-			time.Sleep(backoffDuration)
-			continue
+		if until.Equal(end) {
+			break
 		}
-		return nil
 	}
-	return err
-}
 
-func dateToInterval(date time.Time) int {
-	startOfYear := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
-	weeks := int(date.Sub(startOfYear).Hours()/24/7) + 1
-	return weeks
+	return stargazerHistories, nil
 }
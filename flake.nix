@@ -21,7 +21,7 @@
 
           callPackage = pkgs.darwin.apple_sdk_11_0.callPackage or pkgs.callPackage;
 
-          packageNames = [ "repos" "comments" "history" "sample" "stargazers" ];
+          packageNames = [ "repos" "comments" "history" "sample" "stargazers" "anomalies" ];
 
           buildGoPackage = name: (
             callPackage ./nix/template.nix {
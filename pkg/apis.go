@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	commentquery "github-issue-data/pkg/comment"
+	commitquery "github-issue-data/pkg/commit"
 	issuequery "github-issue-data/pkg/issue"
+	issuesearch "github-issue-data/pkg/issue/search"
 	"github-issue-data/pkg/repos"
 )
 
@@ -29,7 +32,7 @@ func (client *Client) FetchRepos(fetchReposParams *repos.FetchReposParams) ([]Re
 		}
 	}
 
-	resp, err := client.fetch(url)
+	resp, err := client.search(url)
 	if err != nil {
 		return nil, 0, false, err
 	}
@@ -45,6 +48,22 @@ func (client *Client) FetchRepos(fetchReposParams *repos.FetchReposParams) ([]Re
 	return result.Items, result.TotalCount, result.IncompleteResults, err
 }
 
+// FetchRepo fetches a single repo, e.g. for its current star count.
+func (client *Client) FetchRepo(repoFullname string) (*Repo, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s", repoFullname)
+	resp, err := client.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo Repo
+	if err := resp.decode(&repo); err != nil {
+		return nil, err
+	}
+
+	return &repo, nil
+}
+
 func (client *Client) FetchIssues(repoFullname string, issueQuery *issuequery.IssueQuery) ([]Issue, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/issues?%s", repoFullname, issueQuery.ToString())
 	resp, err := client.fetch(url)
@@ -53,13 +72,110 @@ func (client *Client) FetchIssues(repoFullname string, issueQuery *issuequery.Is
 	}
 
 	var issues []Issue
-	err = json.Unmarshal(resp.Body, &issues)
+	if err := resp.decode(&issues); err != nil {
+		return nil, err
+	}
+
+	return issues, nil
+}
+
+func (client *Client) SearchIssues(searchIssuesParams *issuesearch.SearchIssuesParams) ([]Issue, int, bool, error) {
+	url := "https://api.github.com/search/issues"
+
+	if searchIssuesParams != nil {
+		url += "?"
+		if searchIssuesParams.Search != nil {
+			url += "q=" + searchIssuesParams.Search.ToString()
+		}
+
+		url += fmt.Sprintf("&per_page=%d&page=%d", searchIssuesParams.PerPage, searchIssuesParams.Page)
+
+		if searchIssuesParams.Sort != nil {
+			url += fmt.Sprintf("&sort=%s", searchIssuesParams.Sort.Value)
+
+			if searchIssuesParams.Order != nil {
+				url += fmt.Sprintf("&order=%s", searchIssuesParams.Order.Value)
+			}
+		}
+	}
+
+	resp, err := client.search(url)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	var result struct {
+		TotalCount        int     `json:"total_count"`
+		IncompleteResults bool    `json:"incomplete_results"`
+		Items             []Issue `json:"items"`
+	}
+
+	err = json.Unmarshal(resp.Body, &result)
+
+	return result.Items, result.TotalCount, result.IncompleteResults, err
+}
+
+// The search API stops returning results after this many items.
+const searchResultCap = 1000
+
+// SearchAllIssues fetches every issue matching the search that was created
+// between from and to, both inclusive. Windows holding more than the search
+// API will return are split in half by creation date until each fits.
+func (client *Client) SearchAllIssues(searchParams *issuesearch.SearchParams, from time.Time, to time.Time) ([]Issue, error) {
+	search := searchParams.Copy()
+	search.Set(issuesearch.Created(repos.Time{}.Range(from, to)))
 
+	_, totalCount, _, err := client.SearchIssues(issuesearch.NewSearchIssuesParams(
+		issuesearch.SetSearchParams(search),
+		issuesearch.SetPerPage(1),
+	))
 	if err != nil {
-		print(string(resp.Body))
 		return nil, err
 	}
 
+	days := int(to.Sub(from).Hours() / 24)
+	if totalCount > searchResultCap && days > 0 {
+		mid := from.AddDate(0, 0, days/2)
+
+		older, err := client.SearchAllIssues(searchParams, from, mid)
+		if err != nil {
+			return nil, err
+		}
+		newer, err := client.SearchAllIssues(searchParams, mid.AddDate(0, 0, 1), to)
+		if err != nil {
+			return nil, err
+		}
+		return append(older, newer...), nil
+	}
+
+	if totalCount > searchResultCap {
+		fmt.Println("[WARNING] more than", searchResultCap, "issues created on", from.Format("2006-01-02"), "only the first are fetched.")
+	}
+
+	issues := []Issue{}
+	perPage := 100
+	for page := 1; (page-1)*perPage < totalCount && page*perPage <= searchResultCap; page++ {
+		pageIssues, _, incomplete, err := client.SearchIssues(issuesearch.NewSearchIssuesParams(
+			issuesearch.SetSearchParams(search),
+			issuesearch.SetPerPage(perPage),
+			issuesearch.SetPage(page),
+			issuesearch.SetSort(issuesearch.SortByCreated()),
+			issuesearch.SetOrder(repos.Asc()),
+		))
+		if err != nil {
+			return nil, err
+		}
+
+		if incomplete {
+			fmt.Println("[WARNING] incomplete page.")
+		}
+
+		if len(pageIssues) == 0 {
+			break
+		}
+		issues = append(issues, pageIssues...)
+	}
+
 	return issues, nil
 }
 
@@ -71,37 +187,81 @@ func (client Client) FetchCommentsForIssue(repoFullname string, issueNumber int)
 	}
 
 	var comments []Comment
-	err = json.Unmarshal(resp.Body, &comments)
+	if err := resp.decode(&comments); err != nil {
+		return nil, err
+	}
 
+	return comments, nil
+}
+
+// FetchCommentsForRepo lists the issue comments of a whole repository. Each
+// comment carries the issue_url of its issue, so callers can join locally
+// instead of requesting the comments of every issue.
+func (client *Client) FetchCommentsForRepo(repoFullname string, commentQuery *commentquery.CommentQuery) ([]Comment, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/issues/comments?%s", repoFullname, commentQuery.ToString())
+	resp, err := client.fetch(url)
 	if err != nil {
-		print(string(resp.Body))
+		return nil, err
+	}
+
+	var comments []Comment
+	if err := resp.decode(&comments); err != nil {
 		return nil, err
 	}
 
 	return comments, nil
 }
 
-func (client *Client) FetchAllCommitsForRepo(repoFullname string, since time.Time, until time.Time, perPage, page int) ([]Commit, error) {
-	url := fmt.Sprintf(
-		"https://api.github.com/repos/%s/commits?since=%s&until=%sper_page=%d&page=%d",
-		repoFullname,
-		since.Format("2006-01-02T15:04:05Z"),
-		until.Format("2006-01-02T15:04:05Z"),
-		perPage,
-		page,
-	)
+func (client *Client) FetchCommits(repoFullname string, commitQuery *commitquery.CommitQuery) ([]Commit, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/commits?%s", repoFullname, commitQuery.ToString())
 	resp, err := client.fetch(url)
 	if err != nil {
 		return nil, err
 	}
 
 	var commits []Commit
-	err = json.Unmarshal(resp.Body, &commits)
-
-	if err != nil {
-		print(string(resp.Body))
+	if err := resp.decode(&commits); err != nil {
 		return nil, err
 	}
 
 	return commits, nil
 }
+
+const (
+	commitFilesPerPage = 300
+	maxCommitFilePages = 10
+)
+
+// FetchCommit fetches a single commit, which unlike the listing includes its
+// stats and changed files. Past the 3000 files GitHub lists, FilesTruncated is
+// set.
+func (client *Client) FetchCommit(repoFullname string, sha string) (*Commit, error) {
+	var commit *Commit
+
+	// files come 300 to a page, up to 3000 in all
+	for page := 1; page <= maxCommitFilePages; page++ {
+		url := fmt.Sprintf("https://api.github.com/repos/%s/commits/%s?page=%d", repoFullname, sha, page)
+		resp, err := client.fetch(url)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageCommit Commit
+		if err := resp.decode(&pageCommit); err != nil {
+			return nil, err
+		}
+
+		if commit == nil {
+			commit = &pageCommit
+		} else {
+			commit.Files = append(commit.Files, pageCommit.Files...)
+		}
+
+		if len(pageCommit.Files) < commitFilesPerPage {
+			return commit, nil
+		}
+	}
+
+	commit.FilesTruncated = true
+	return commit, nil
+}
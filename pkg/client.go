@@ -2,30 +2,42 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"golang.org/x/time/rate"
 	"io"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/machinebox/graphql"
 )
 
 type Client struct {
-	httpClient   *http.Client
-	headers      http.Header
-	limiter      *rate.Limiter
-	RequestCount int
+	httpClient    *http.Client
+	graphqlClient *graphql.Client
+	headers       http.Header
+	limiter       *rate.Limiter
+	searchLimiter *rate.Limiter
+	RequestCount  int
 }
 
 func NewClient(token string) *Client {
 	limiter := rate.NewLimiter(rate.Limit((5000./(60.*60.))-0.1), 1)
 
+	httpClient := &http.Client{}
+
 	return &Client{
-		httpClient: &http.Client{},
+		httpClient:    httpClient,
+		graphqlClient: graphql.NewClient("https://api.github.com/graphql", graphql.WithHTTPClient(&http.Client{Transport: statusTransport{http.DefaultTransport}})),
 		headers: http.Header{
 			"Accept":               {"application/vnd.github+json"},
 			"Authorization":        {"Bearer " + token},
 			"X-GitHub-Api-Version": {"2022-11-28"},
 		},
-		limiter: limiter,
+		limiter:       limiter,
+		searchLimiter: rate.NewLimiter(rate.Limit((30./60.)-0.01), 1),
 	}
 }
 
@@ -34,7 +46,29 @@ type Response struct {
 	Body       []byte
 }
 
+// maxErrorBody is how much of a response body an error quotes.
+const maxErrorBody = 200
+
+// decode unmarshals the JSON body into v. When that fails, the error holds the
+// status and the start of the body, which is usually GitHub's error message.
+func (resp *Response) decode(v any) error {
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		body := string(resp.Body)
+		if len(body) > maxErrorBody {
+			body = body[:maxErrorBody] + "..."
+		}
+		return fmt.Errorf("status %d: %s: %w", resp.StatusCode, body, err)
+	}
+	return nil
+}
+
 func (client *Client) fetch(url string) (*Response, error) {
+	return client.fetchAccept(url, "")
+}
+
+// fetchAccept fetches with another media type than the default one, for
+// endpoints that return extra fields with a custom media type.
+func (client *Client) fetchAccept(url string, accept string) (*Response, error) {
 	if err := client.limiter.Wait(context.Background()); err != nil {
 		fmt.Println("Rate limiter error:", err)
 		return nil, err
@@ -46,7 +80,10 @@ func (client *Client) fetch(url string) (*Response, error) {
 		fmt.Println("Error on request.\n[ERROR] -", err)
 		return nil, err
 	}
-	req.Header = client.headers
+	req.Header = client.headers.Clone()
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
 
 	resp, err := client.httpClient.Do(req)
 	if err != nil {
@@ -62,3 +99,94 @@ func (client *Client) fetch(url string) (*Response, error) {
 		Body:       body,
 	}, err
 }
+
+// search fetches from the search API, which has its own, much lower quota on
+// top of the regular one.
+func (client *Client) search(url string) (*Response, error) {
+	if err := client.searchLimiter.Wait(context.Background()); err != nil {
+		fmt.Println("Rate limiter error:", err)
+		return nil, err
+	}
+
+	return client.fetch(url)
+}
+
+// StatusError is a GraphQL response with an error status, which the graphql
+// client would otherwise decode into an empty result.
+type StatusError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", err.StatusCode, err.Body)
+}
+
+type statusTransport struct {
+	base http.RoundTripper
+}
+
+func (transport statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := transport.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 300 {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return nil, &StatusError{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)}
+}
+
+// isTransient reports whether a failed query may succeed when retried: server
+// errors, rate limits, timeouts and network errors. Bad credentials, missing
+// scopes and errors in the query itself fail the same way every time.
+func isTransient(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500:
+			return true
+		case statusErr.StatusCode == http.StatusForbidden:
+			// secondary rate limits are a 403 too
+			return statusErr.Header.Get("Retry-After") != "" || statusErr.Header.Get("X-RateLimit-Remaining") == "0"
+		}
+		return false
+	}
+
+	if message := strings.ToLower(err.Error()); strings.HasPrefix(message, "graphql: ") {
+		return strings.Contains(message, "rate limit") ||
+			strings.Contains(message, "timeout") ||
+			strings.Contains(message, "something went wrong")
+	}
+
+	return true
+}
+
+const maxQueryRetries = 5
+
+// query runs a GraphQL request against the same rate limit as the REST calls,
+// retrying with a growing backoff when the request fails transiently.
+func (client *Client) query(req *graphql.Request, respData interface{}) error {
+	req.Header.Set("Authorization", client.headers.Get("Authorization"))
+
+	var err error
+	for attempt := 1; attempt <= maxQueryRetries; attempt++ {
+		if err := client.limiter.Wait(context.Background()); err != nil {
+			fmt.Println("Rate limiter error:", err)
+			return err
+		}
+
+		client.RequestCount++
+		err = client.graphqlClient.Run(context.Background(), req, respData)
+		if err == nil || !isTransient(err) {
+			return err
+		}
+
+		backoffDuration := time.Duration(attempt*attempt) * time.Second
+		fmt.Printf("Attempt %d: query failed: %s\nWaiting for %s before retrying...\n", attempt, err, backoffDuration)
+		time.Sleep(backoffDuration)
+	}
+
+	return err
+}
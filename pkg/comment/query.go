@@ -0,0 +1,86 @@
+package comment
+
+import (
+	"fmt"
+	"time"
+
+	issuequery "github-issue-data/pkg/issue"
+)
+
+type CommentQuery struct {
+	internal map[string]string
+}
+
+func NewCommentQuery(options ...func(*CommentQuery)) *CommentQuery {
+	commentQuery := &CommentQuery{
+		internal: map[string]string{},
+	}
+
+	for _, option := range options {
+		option(commentQuery)
+	}
+
+	return commentQuery
+}
+
+func (query *CommentQuery) Set(options ...func(*CommentQuery)) {
+	for _, option := range options {
+		option(query)
+	}
+}
+
+func setParam(key string, value string) func(*CommentQuery) {
+	return func(commentQuery *CommentQuery) {
+		commentQuery.internal[key] = value
+	}
+}
+
+// Since only keeps comments updated at or after the given time.
+func Since(value time.Time) func(*CommentQuery) {
+	return setParam("since", value.UTC().Format(time.RFC3339))
+}
+
+func Page(value int) func(*CommentQuery) {
+	return setParam("page", fmt.Sprint(value))
+}
+
+func PerPage(value int) func(*CommentQuery) {
+	return setParam("per_page", fmt.Sprint(value))
+}
+
+type QuerySort struct {
+	value string
+}
+
+func Sort(value QuerySort) func(*CommentQuery) {
+	return setParam("sort", value.value)
+}
+
+func Created() QuerySort {
+	return QuerySort{"created"}
+}
+
+func Updated() QuerySort {
+	return QuerySort{"updated"}
+}
+
+type QueryDirection struct {
+	value string
+}
+
+func Direction(value QueryDirection) func(*CommentQuery) {
+	return setParam("direction", value.value)
+}
+
+func Asc() QueryDirection {
+	return QueryDirection{"asc"}
+}
+
+func Desc() QueryDirection {
+	return QueryDirection{"desc"}
+}
+
+// ToString encodes the query with keys in sorted order.
+func (commentQuery CommentQuery) ToString() string {
+	return issuequery.EncodeParams(commentQuery.internal)
+}
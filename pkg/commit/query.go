@@ -0,0 +1,81 @@
+package commit
+
+import (
+	"fmt"
+	"time"
+
+	issuequery "github-issue-data/pkg/issue"
+)
+
+type CommitQuery struct {
+	internal map[string]string
+}
+
+func NewCommitQuery(options ...func(*CommitQuery)) *CommitQuery {
+	commitQuery := &CommitQuery{
+		internal: map[string]string{},
+	}
+
+	for _, option := range options {
+		option(commitQuery)
+	}
+
+	return commitQuery
+}
+
+func (query *CommitQuery) Set(options ...func(*CommitQuery)) {
+	for _, option := range options {
+		option(query)
+	}
+}
+
+func setParam(key string, value string) func(*CommitQuery) {
+	return func(commitQuery *CommitQuery) {
+		commitQuery.internal[key] = value
+	}
+}
+
+// SHA starts listing from a commit SHA or branch name instead of the default
+// branch.
+func SHA(value string) func(*CommitQuery) {
+	return setParam("sha", value)
+}
+
+func Branch(name string) func(*CommitQuery) {
+	return SHA(name)
+}
+
+// Path only keeps commits touching the given file or directory.
+func Path(value string) func(*CommitQuery) {
+	return setParam("path", value)
+}
+
+// Author matches a GitHub login or an email address.
+func Author(value string) func(*CommitQuery) {
+	return setParam("author", value)
+}
+
+func Committer(value string) func(*CommitQuery) {
+	return setParam("committer", value)
+}
+
+func Since(value time.Time) func(*CommitQuery) {
+	return setParam("since", value.UTC().Format(time.RFC3339))
+}
+
+func Until(value time.Time) func(*CommitQuery) {
+	return setParam("until", value.UTC().Format(time.RFC3339))
+}
+
+func Page(value int) func(*CommitQuery) {
+	return setParam("page", fmt.Sprint(value))
+}
+
+func PerPage(value int) func(*CommitQuery) {
+	return setParam("per_page", fmt.Sprint(value))
+}
+
+// ToString encodes the query with keys in sorted order.
+func (commitQuery CommitQuery) ToString() string {
+	return issuequery.EncodeParams(commitQuery.internal)
+}
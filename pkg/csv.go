@@ -1,84 +1,542 @@
 package github
 
 import (
+	"encoding"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func SaveToCSV(data interface{}, filename string) error {
-	file, err := os.Create(filename)
+// CSVMarshaler is implemented by types that write themselves as a single CSV
+// value instead of being formatted or flattened.
+type CSVMarshaler interface {
+	MarshalCSV() (string, error)
+}
+
+// CSVSink streams rows into <path>.csv as they are collected, the format
+// commands read their inputs in.
+type CSVSink struct {
+	file    *os.File
+	writer  *csv.Writer
+	rowType reflect.Type
+	columns []csvColumn
+}
+
+// Open creates or truncates the file and writes the header right away, so a
+// run that collects no rows still leaves a valid dataset.
+func (sink *CSVSink) Open(path string, rowType reflect.Type) error {
+	columns, err := csvColumns(rowType)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	file, err := os.Create(path + ".csv")
+	if err != nil {
+		return err
+	}
 
-	writer.Comma = ','
-	writer.UseCRLF = false
+	*sink = CSVSink{file: file, writer: newWriter(file), rowType: rowType, columns: columns}
+	if err := sink.writeRecord(csvHeader(columns)); err != nil {
+		file.Close()
+		return err
+	}
 
-	v := reflect.ValueOf(data)
-	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
-		return fmt.Errorf("data must be a pointer to a slice")
+	return nil
+}
+
+// Append adds to an existing file, failing when its header does not match the
+// columns of the rows. A header missing the last columns, from before they
+// were added, is rewritten with them and the rows already there are left
+// empty in them. A missing or empty file is started with the header as Open
+// does.
+func (sink *CSVSink) Append(path string, rowType reflect.Type) error {
+	columns, err := csvColumns(rowType)
+	if err != nil {
+		return err
 	}
+	header := csvHeader(columns)
 
-	slice := v.Elem()
-	if slice.Len() == 0 {
-		return fmt.Errorf("slice is empty")
+	filename := path + ".csv"
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	*sink = CSVSink{file: file, writer: newWriter(file), rowType: rowType, columns: columns}
+
+	reader := csv.NewReader(file)
+	existing, err := reader.Read()
+	switch {
+	case err == io.EOF:
+		err = sink.writeRecord(header)
+	case err != nil:
+	case slices.Equal(existing, header):
+		_, err = file.Seek(0, io.SeekEnd)
+	case isOlderHeader(existing, columns):
+		err = sink.rewrite(filename, reader, header)
+	default:
+		err = fmt.Errorf("%s has columns %v, expected %v", filename, existing, header)
+	}
+	if err != nil {
+		sink.file.Close()
+		return err
 	}
 
-	firstElem := slice.Index(0)
-	header, err := structTagsToSlice(firstElem.Interface())
+	return nil
+}
+
+// rewrite replaces the file with a copy under the given header, the rows read
+// from reader padded with empty values, and goes on appending to the copy. The
+// copy is renamed over the file once complete, so a crash leaves either one.
+func (sink *CSVSink) rewrite(filename string, reader *csv.Reader, header []string) error {
+	records, err := reader.ReadAll()
 	if err != nil {
 		return err
 	}
-	if err := writer.Write(header); err != nil {
+
+	upgraded, err := os.Create(filename + ".tmp")
+	if err != nil {
 		return err
 	}
 
-	for i := 0; i < slice.Len(); i++ {
-		elem := slice.Index(i).Interface()
-		row, err := structToStringSlice(elem)
+	writer := newWriter(upgraded)
+	writer.Write(header)
+	for _, record := range records {
+		writer.Write(append(record, make([]string, len(header)-len(record))...))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		upgraded.Close()
+		os.Remove(upgraded.Name())
+		return err
+	}
 
-		if err != nil {
-			return err
-		}
-		if err := writer.Write(row); err != nil {
-			return err
-		}
+	if err := os.Rename(upgraded.Name(), filename); err != nil {
+		upgraded.Close()
+		os.Remove(upgraded.Name())
+		return err
 	}
 
+	sink.file.Close()
+	sink.file = upgraded
+	sink.writer = newWriter(upgraded)
 	return nil
 }
 
-func structToStringSlice(data interface{}) ([]string, error) {
-	v := reflect.ValueOf(data)
-	if v.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("data must be a struct")
+// Write buffers a row, which is only written out by Flush.
+func (sink *CSVSink) Write(row any) error {
+	if err := checkRowType(row, sink.rowType); err != nil {
+		return err
 	}
 
-	var row []string
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		row = append(row, fmt.Sprintf("%v", field.Interface()))
+	record, err := encodeCSVRow(reflect.ValueOf(row), sink.columns)
+	if err != nil {
+		return err
 	}
-	return row, nil
+	return sink.writer.Write(record)
+}
+
+func (sink *CSVSink) Flush() error {
+	sink.writer.Flush()
+	return sink.writer.Error()
+}
+
+func (sink *CSVSink) Close() error {
+	if err := sink.Flush(); err != nil {
+		sink.file.Close()
+		return err
+	}
+
+	return sink.file.Close()
+}
+
+func (sink *CSVSink) writeRecord(record []string) error {
+	if err := sink.writer.Write(record); err != nil {
+		return err
+	}
+
+	return sink.Flush()
+}
+
+// isOlderHeader reports whether a header holds the columns in order, or the
+// first of them as long as no required column is left out.
+func isOlderHeader(header []string, columns []csvColumn) bool {
+	if len(header) > len(columns) || !slices.Equal(header, csvHeader(columns[:len(header)])) {
+		return false
+	}
+
+	for _, column := range columns[len(header):] {
+		if column.required {
+			return false
+		}
+	}
+	return true
+}
+
+func newWriter(file io.Writer) *csv.Writer {
+	writer := csv.NewWriter(file)
+	writer.Comma = ','
+	writer.UseCRLF = false
+	return writer
 }
 
-func structTagsToSlice(data interface{}) ([]string, error) {
-	t := reflect.TypeOf(data)
+// csvColumn is a column of a struct flattened into CSV, with the path of field
+// indexes leading to its value.
+type csvColumn struct {
+	name     string
+	index    []int
+	required bool
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	csvMarshalerType  = reflect.TypeFor[CSVMarshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// csvColumns lists the columns of a struct. Nested structs are flattened into
+// dotted columns such as user.id, except those written as a single value.
+func csvColumns(t reflect.Type) ([]csvColumn, error) {
 	if t.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("data must be a struct")
 	}
 
-	var header []string
+	return appendCSVColumns(nil, t, "", nil), nil
+}
+
+func appendCSVColumns(columns []csvColumn, t reflect.Type, prefix string, index []int) []csvColumn {
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		tag := field.Tag.Get("json")
-		header = append(header, tag)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, tagged, ok := csvColumnName(field)
+		if !ok {
+			continue
+		}
+		_, options, _ := strings.Cut(field.Tag.Get("csv"), ",")
+
+		fieldIndex := append(slices.Clone(index), i)
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if isCSVValue(fieldType) {
+			columns = append(columns, csvColumn{
+				name:     prefix + name,
+				index:    fieldIndex,
+				required: slices.Contains(strings.Split(options, ","), "required"),
+			})
+		} else if field.Anonymous && !tagged {
+			// untagged embedded structs keep their columns at the same level
+			columns = appendCSVColumns(columns, fieldType, prefix, fieldIndex)
+		} else {
+			columns = appendCSVColumns(columns, fieldType, prefix+name+".", fieldIndex)
+		}
+	}
+
+	return columns
+}
+
+// csvColumnName names a column after the csv tag, then the json tag without
+// its options, then the field. A "-" tag leaves the field out. The csv tag can
+// give only options, as in `csv:",required"`, keeping the json name.
+func csvColumnName(field reflect.StructField) (string, bool, bool) {
+	for _, key := range []string{"csv", "json"} {
+		tag, found := field.Tag.Lookup(key)
+		if !found {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return "", true, false
+		}
+		if name != "" {
+			return name, true, true
+		}
+	}
+
+	return field.Name, false, true
+}
+
+// isCSVValue reports whether a type is written as one value rather than
+// flattened into columns.
+func isCSVValue(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return true
+	}
+
+	pointer := reflect.PointerTo(t)
+	return pointer.Implements(csvMarshalerType) || pointer.Implements(textMarshalerType)
+}
+
+func csvHeader(columns []csvColumn) []string {
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	return header
+}
+
+func encodeCSVRow(v reflect.Value, columns []csvColumn) ([]string, error) {
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("data must be a struct")
 	}
-	return header, nil
+
+	addressable := addressableCopy(v)
+
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		field, ok := csvField(addressable, column.index)
+		if !ok {
+			continue
+		}
+
+		value, err := formatCSVValue(field)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column.name, err)
+		}
+		row[i] = value
+	}
+
+	return row, nil
+}
+
+// addressableCopy copies v into a new variable, so the fields reached from it
+// are addressable and marshalers with pointer receivers are found.
+func addressableCopy(v reflect.Value) reflect.Value {
+	addressable := reflect.New(v.Type()).Elem()
+	addressable.Set(v)
+	return addressable
+}
+
+// csvField follows the index path through nested structs, failing on a nil
+// pointer along the way so its columns are left empty.
+func csvField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+
+	return v, true
+}
+
+// formatCSVValue writes nil as an empty string, times as RFC 3339 and
+// marshalers through their own method. Anything else is formatted with %v.
+func formatCSVValue(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return "", nil
+		}
+	}
+
+	if v.Kind() != reflect.Pointer && v.CanAddr() {
+		v = v.Addr()
+	}
+
+	if marshaler, ok := v.Interface().(CSVMarshaler); ok {
+		return marshaler.MarshalCSV()
+	}
+
+	if t, ok := reflect.Indirect(v).Interface().(time.Time); ok {
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	return fmt.Sprintf("%v", reflect.Indirect(v).Interface()), nil
+}
+
+// CSVUnmarshaler is the inverse of CSVMarshaler for reading.
+type CSVUnmarshaler interface {
+	UnmarshalCSV(string) error
+}
+
+// CSVReader reads rows written by CSVSink back into T, mapping
+// columns to fields by name. Columns unknown to T are skipped and fields
+// without a column are left zero, unless tagged `csv:",required"`.
+type CSVReader[T any] struct {
+	reader  *csv.Reader
+	columns []csvColumn
+	// positions holds the record index of every column, -1 when missing
+	positions []int
+}
+
+func NewCSVReader[T any](r io.Reader) (*CSVReader[T], error) {
+	columns, err := csvColumns(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("missing CSV header")
+	} else if err != nil {
+		return nil, err
+	}
+
+	positions := make([]int, len(columns))
+	for i, column := range columns {
+		positions[i] = slices.Index(header, column.name)
+		if positions[i] < 0 && column.required {
+			return nil, fmt.Errorf("missing required column %s", column.name)
+		}
+	}
+
+	return &CSVReader[T]{reader: reader, columns: columns, positions: positions}, nil
+}
+
+// Read returns the next row, or io.EOF after the last one. Errors name the
+// line and column of the value that failed to parse.
+func (csvReader *CSVReader[T]) Read() (T, error) {
+	var row T
+
+	record, err := csvReader.reader.Read()
+	if err != nil {
+		return row, err
+	}
+
+	v := reflect.ValueOf(&row).Elem()
+	for i, column := range csvReader.columns {
+		position := csvReader.positions[i]
+		if position < 0 || record[position] == "" {
+			continue
+		}
+
+		if err := parseCSVValue(csvSettableField(v, column.index), record[position]); err != nil {
+			line, _ := csvReader.reader.FieldPos(position)
+			return row, fmt.Errorf("line %d, column %s: %w", line, column.name, err)
+		}
+	}
+
+	return row, nil
+}
+
+// LoadFromCSV reads every row of a file, the inverse of CSVSink.
+func LoadFromCSV[T any](filename string) ([]T, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	csvReader, err := NewCSVReader[T](file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	rows := []T{}
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// csvSettableField follows the index path like csvField, allocating nil
+// pointers along the way. Empty values are never set, so pointers whose
+// columns are all empty stay nil.
+func csvSettableField(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+
+	return v
+}
+
+// parseCSVValue is the inverse of formatCSVValue.
+func parseCSVValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if unmarshaler, ok := v.Addr().Interface().(CSVUnmarshaler); ok {
+		return unmarshaler.UnmarshalCSV(value)
+	}
+
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot read %s from CSV", v.Type())
+	}
+
+	return nil
 }
@@ -0,0 +1,288 @@
+package github
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type csvTestUser struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+}
+
+type csvTestLevel int
+
+func (level csvTestLevel) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("*", int(level))), nil
+}
+
+func (level *csvTestLevel) UnmarshalText(text []byte) error {
+	*level = csvTestLevel(len(text))
+	return nil
+}
+
+// CSVTestSource is exported, as unexported embedded structs are left out.
+type CSVTestSource struct {
+	Source string `json:"source"`
+}
+
+type csvTestRow struct {
+	CSVTestSource
+	ID        int          `json:"id" csv:",required"`
+	Title     string       `csv:"title"`
+	Score     float64      `json:"score"`
+	Draft     bool         `json:"draft"`
+	User      csvTestUser  `json:"user"`
+	Assignee  *csvTestUser `json:"assignee"`
+	CreatedAt time.Time    `json:"created_at"`
+	Level     csvTestLevel `json:"level"`
+	Labels    []string     `json:"labels"`
+	Secret    string       `json:"-"`
+	internal  string
+}
+
+func TestCSVColumns(t *testing.T) {
+	columns, err := csvColumns(reflect.TypeFor[csvTestRow]())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"source",
+		"id",
+		"title",
+		"score",
+		"draft",
+		"user.id",
+		"user.login",
+		"assignee.id",
+		"assignee.login",
+		"created_at",
+		"level",
+		"labels",
+	}
+	if got := csvHeader(columns); !slices.Equal(got, want) {
+		t.Errorf("csvColumns = %v, want %v", got, want)
+	}
+
+	for _, column := range columns {
+		if column.required != (column.name == "id") {
+			t.Errorf("column %s required = %v", column.name, column.required)
+		}
+	}
+
+	if _, err := csvColumns(reflect.TypeFor[int]()); err == nil {
+		t.Error("csvColumns(int) succeeded, want an error")
+	}
+}
+
+func TestEncodeCSVRow(t *testing.T) {
+	columns, err := csvColumns(reflect.TypeFor[csvTestRow]())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		row  csvTestRow
+		want []string
+	}{
+		{
+			name: "zero",
+			row:  csvTestRow{},
+			want: []string{"", "0", "", "0", "false", "0", "", "", "", "", "", ""},
+		},
+		{
+			name: "filled",
+			row: csvTestRow{
+				CSVTestSource: CSVTestSource{Source: "rest"},
+				ID:            7,
+				Title:         "a, \"quoted\" title",
+				Score:         1.5,
+				Draft:         true,
+				User:          csvTestUser{ID: 1, Login: "octocat"},
+				Assignee:      &csvTestUser{ID: 2, Login: "hubot"},
+				CreatedAt:     time.Date(2016, time.January, 2, 3, 4, 5, 0, time.UTC),
+				Level:         3,
+				Labels:        []string{"bug", "help"},
+				Secret:        "left out",
+			},
+			want: []string{"rest", "7", "a, \"quoted\" title", "1.5", "true", "1", "octocat", "2", "hubot", "2016-01-02T03:04:05Z", "***", "[bug help]"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := encodeCSVRow(reflect.ValueOf(test.row), columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("encodeCSVRow = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	rows := []csvTestRow{
+		{ID: 1},
+		{
+			CSVTestSource: CSVTestSource{Source: "graphql"},
+			ID:            2,
+			Title:         "multi\nline, \"quoted\"",
+			Score:         -0.25,
+			Draft:         true,
+			User:          csvTestUser{ID: 3, Login: "octocat"},
+			Assignee:      &csvTestUser{ID: 4, Login: "hubot"},
+			CreatedAt:     time.Date(2019, time.December, 31, 23, 59, 59, 0, time.UTC),
+			Level:         2,
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "rows")
+	writeDataset(t, OpenSink, "csv", path, rows...)
+
+	got, err := LoadFromCSV[csvTestRow](path + ".csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("LoadFromCSV = %+v, want %+v", got, rows)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []csvTestUser
+	}{
+		{"in order", "id,login\n1,octocat\n2,hubot\n", []csvTestUser{{1, "octocat"}, {2, "hubot"}}},
+		{"reordered", "login,id\nocto,1\n", []csvTestUser{{1, "octo"}}},
+		{"unknown column skipped", "id,extra,login\n1,x,octocat\n", []csvTestUser{{1, "octocat"}}},
+		{"missing column zero", "id\n1\n", []csvTestUser{{ID: 1}}},
+		{"empty value zero", "id,login\n,octocat\n", []csvTestUser{{Login: "octocat"}}},
+		{"header only", "id,login\n", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewCSVReader[csvTestUser](strings.NewReader(test.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []csvTestUser
+			for {
+				row, err := reader.Read()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, row)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("read %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCSVReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want string
+	}{
+		{"empty", "", "missing CSV header"},
+		{"missing required column", "title\nx\n", "missing required column id"},
+		{"invalid int", "id\n1\nx\n", "line 3, column id"},
+		{"invalid time", "id,created_at\n1,2016-01-01\n", "line 2, column created_at"},
+		{"invalid bool", "id,draft\n1,maybe\n", "line 2, column draft"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewCSVReader[csvTestRow](strings.NewReader(test.csv))
+			for err == nil {
+				_, err = reader.Read()
+			}
+			if err == io.EOF || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCSVAppend(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+		fails    bool
+	}{
+		{"missing file", "", "id,login\n2,hubot\n", false},
+		{"same header", "id,login\n1,octocat\n", "id,login\n1,octocat\n2,hubot\n", false},
+		{"older header", "id\n1\n", "id,login\n1,\n2,hubot\n", false},
+		{"other header", "login,id\noctocat,1\n", "", true},
+		{"more columns", "id,login,extra\n1,octocat,x\n", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users")
+			if test.existing != "" {
+				if err := os.WriteFile(path+".csv", []byte(test.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sink, err := AppendSink("csv", path, csvTestUser{})
+			if test.fails {
+				if err == nil {
+					sink.Close()
+					t.Fatal("appended to a file with other columns")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := WriteRows(sink, csvTestUser{ID: 2, Login: "hubot"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := os.ReadFile(path + ".csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("file holds %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCSVAppendRequiredColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows")
+	if err := os.WriteFile(path+".csv", []byte("source\nrest\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if sink, err := AppendSink("csv", path, csvTestRow{}); err == nil {
+		sink.Close()
+		t.Error("appended to a file missing a required column")
+	}
+}
@@ -0,0 +1,211 @@
+package gitlog
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is a commit read from a local clone, with the same details the API
+// returns apart from GitHub logins.
+type Commit struct {
+	SHA            string
+	Parents        []string
+	AuthorName     string
+	AuthorEmail    string
+	AuthorDate     time.Time
+	CommitterName  string
+	CommitterEmail string
+	CommitterDate  time.Time
+	Message        string
+	// Signature is the %G? status of git log, "G" for a good signature and
+	// empty unless signatures are verified.
+	Signature    string
+	Additions    int
+	Deletions    int
+	FilesChanged int
+}
+
+type LogQuery struct {
+	ref        string
+	since      *time.Time
+	until      *time.Time
+	paths      []string
+	mailmap    bool
+	noMerges   bool
+	signatures bool
+}
+
+func NewLogQuery(options ...func(*LogQuery)) *LogQuery {
+	logQuery := &LogQuery{
+		ref: "HEAD",
+	}
+
+	for _, option := range options {
+		option(logQuery)
+	}
+
+	return logQuery
+}
+
+func (query *LogQuery) Set(options ...func(*LogQuery)) {
+	for _, option := range options {
+		option(query)
+	}
+}
+
+// Ref logs from a branch or SHA instead of HEAD, the default branch of a bare
+// clone.
+func Ref(value string) func(*LogQuery) {
+	return func(logQuery *LogQuery) {
+		logQuery.ref = value
+	}
+}
+
+func Since(value time.Time) func(*LogQuery) {
+	return func(logQuery *LogQuery) {
+		logQuery.since = &value
+	}
+}
+
+func Until(value time.Time) func(*LogQuery) {
+	return func(logQuery *LogQuery) {
+		logQuery.until = &value
+	}
+}
+
+// Path only keeps commits touching the file or directory. It can be given
+// more than once.
+func Path(value string) func(*LogQuery) {
+	return func(logQuery *LogQuery) {
+		logQuery.paths = append(logQuery.paths, value)
+	}
+}
+
+// Mailmap maps names and emails through the repo's .mailmap, read from HEAD in
+// a bare clone.
+func Mailmap(value bool) func(*LogQuery) {
+	return func(logQuery *LogQuery) {
+		logQuery.mailmap = value
+	}
+}
+
+func NoMerges(value bool) func(*LogQuery) {
+	return func(logQuery *LogQuery) {
+		logQuery.noMerges = value
+	}
+}
+
+// VerifySignatures checks the signature of every commit, which needs gpg and
+// is slow on long histories.
+func VerifySignatures(value bool) func(*LogQuery) {
+	return func(logQuery *LogQuery) {
+		logQuery.signatures = value
+	}
+}
+
+const (
+	recordSeparator = "\x1e"
+	fieldSeparator  = "\x1f"
+)
+
+func (query *LogQuery) args(gitDir string) []string {
+	placeholders := []string{"%H", "%P", "%an", "%ae", "%aI", "%cn", "%ce", "%cI", "", "%B"}
+	if query.mailmap {
+		placeholders[2], placeholders[3] = "%aN", "%aE"
+		placeholders[5], placeholders[6] = "%cN", "%cE"
+	}
+	if query.signatures {
+		placeholders[8] = "%G?"
+	}
+	format := recordSeparator + strings.Join(placeholders, fieldSeparator) + fieldSeparator
+
+	args := []string{"--git-dir=" + gitDir, "log", "--format=" + format, "--numstat"}
+	if query.noMerges {
+		args = append(args, "--no-merges")
+	}
+	if query.since != nil {
+		args = append(args, "--since="+query.since.UTC().Format(time.RFC3339))
+	}
+	if query.until != nil {
+		args = append(args, "--until="+query.until.UTC().Format(time.RFC3339))
+	}
+
+	args = append(args, query.ref, "--")
+	return append(args, query.paths...)
+}
+
+// Log reads the commits of a clone, usually a bare one, by running git log.
+func Log(gitDir string, query *LogQuery) ([]Commit, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", query.args(gitDir)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git log in %s: %w: %s", gitDir, err, strings.TrimSpace(stderr.String()))
+	}
+
+	commits := []Commit{}
+	for _, record := range strings.Split(stdout.String(), recordSeparator) {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+
+		commit, err := parseRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, *commit)
+	}
+
+	return commits, nil
+}
+
+func parseRecord(record string) (*Commit, error) {
+	fields := strings.Split(record, fieldSeparator)
+	if len(fields) != 11 {
+		return nil, fmt.Errorf("malformed git log record %q", record)
+	}
+
+	authorDate, err := time.Parse(time.RFC3339, fields[4])
+	if err != nil {
+		return nil, err
+	}
+	committerDate, err := time.Parse(time.RFC3339, fields[7])
+	if err != nil {
+		return nil, err
+	}
+
+	commit := &Commit{
+		SHA:            fields[0],
+		Parents:        strings.Fields(fields[1]),
+		AuthorName:     fields[2],
+		AuthorEmail:    fields[3],
+		AuthorDate:     authorDate,
+		CommitterName:  fields[5],
+		CommitterEmail: fields[6],
+		CommitterDate:  committerDate,
+		Signature:      fields[8],
+		Message:        strings.TrimRight(fields[9], "\n"),
+	}
+
+	// --numstat lines follow the formatted fields, binary files count as "-"
+	for _, line := range strings.Split(fields[10], "\n") {
+		stat := strings.SplitN(line, "\t", 3)
+		if len(stat) != 3 {
+			continue
+		}
+
+		additions, _ := strconv.Atoi(stat[0])
+		deletions, _ := strconv.Atoi(stat[1])
+		commit.Additions += additions
+		commit.Deletions += deletions
+		commit.FilesChanged++
+	}
+
+	return commit, nil
+}
@@ -0,0 +1,317 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/machinebox/graphql"
+)
+
+type ReactionGroup struct {
+	Content string `json:"content"`
+	Count   int    `json:"count"`
+}
+
+type TimelineItem struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ThreadComment struct {
+	Comment
+	Reactions []ReactionGroup `json:"reactions"`
+}
+
+// IssueThread is an issue together with everything the GraphQL bulk query
+// returns for it, so a repo can be collected without per-issue REST calls.
+type IssueThread struct {
+	Issue     Issue           `json:"issue"`
+	Comments  []ThreadComment `json:"comments"`
+	Reactions []ReactionGroup `json:"reactions"`
+	Timeline  []TimelineItem  `json:"timeline"`
+}
+
+const actorFields = `
+	__typename
+	login
+	... on User { databaseId }
+	... on Bot { databaseId }
+	... on Organization { databaseId }
+	... on Mannequin { databaseId }
+`
+
+const commentFields = `
+	databaseId
+	body
+	createdAt
+	updatedAt
+	authorAssociation
+	author {` + actorFields + `}
+	reactionGroups { content reactors { totalCount } }
+`
+
+const issueThreadsQuery = `
+	query ($owner: String!, $name: String!, $states: [IssueState!], $since: DateTime, $cursor: String) {
+		repository(owner: $owner, name: $name) {
+			issues(first: 25, after: $cursor, states: $states, filterBy: {since: $since}, orderBy: {field: CREATED_AT, direction: DESC}) {
+				nodes {
+					id
+					databaseId
+					number
+					title
+					body
+					state
+					createdAt
+					updatedAt
+					authorAssociation
+					author {` + actorFields + `}
+					labels(first: 20) { nodes { name color description } }
+					reactionGroups { content reactors { totalCount } }
+					timelineItems(first: 100, itemTypes: [CLOSED_EVENT, REOPENED_EVENT, LABELED_EVENT, UNLABELED_EVENT, ASSIGNED_EVENT, CROSS_REFERENCED_EVENT, REFERENCED_EVENT]) {
+						nodes {
+							__typename
+							... on ClosedEvent { createdAt }
+							... on ReopenedEvent { createdAt }
+							... on LabeledEvent { createdAt }
+							... on UnlabeledEvent { createdAt }
+							... on AssignedEvent { createdAt }
+							... on CrossReferencedEvent { createdAt }
+							... on ReferencedEvent { createdAt }
+						}
+					}
+					comments(first: 100) {
+						totalCount
+						nodes {` + commentFields + `}
+						pageInfo { endCursor hasNextPage }
+					}
+				}
+				pageInfo { endCursor hasNextPage }
+			}
+		}
+	}
+`
+
+const issueCommentsQuery = `
+	query ($id: ID!, $cursor: String) {
+		node(id: $id) {
+			... on Issue {
+				comments(first: 100, after: $cursor) {
+					nodes {` + commentFields + `}
+					pageInfo { endCursor hasNextPage }
+				}
+			}
+		}
+	}
+`
+
+type pageInfo struct {
+	EndCursor   string `json:"endCursor"`
+	HasNextPage bool   `json:"hasNextPage"`
+}
+
+type actorNode struct {
+	Typename   string `json:"__typename"`
+	Login      string `json:"login"`
+	DatabaseID int    `json:"databaseId"`
+}
+
+type reactionGroupNode struct {
+	Content  string `json:"content"`
+	Reactors struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"reactors"`
+}
+
+type commentNode struct {
+	DatabaseID        int                 `json:"databaseId"`
+	Body              string              `json:"body"`
+	CreatedAt         time.Time           `json:"createdAt"`
+	UpdatedAt         time.Time           `json:"updatedAt"`
+	AuthorAssociation string              `json:"authorAssociation"`
+	Author            *actorNode          `json:"author"`
+	ReactionGroups    []reactionGroupNode `json:"reactionGroups"`
+}
+
+type commentConnection struct {
+	TotalCount int           `json:"totalCount"`
+	Nodes      []commentNode `json:"nodes"`
+	PageInfo   pageInfo      `json:"pageInfo"`
+}
+
+type issueNode struct {
+	ID                string     `json:"id"`
+	DatabaseID        int        `json:"databaseId"`
+	Number            int        `json:"number"`
+	Title             string     `json:"title"`
+	Body              string     `json:"body"`
+	State             string     `json:"state"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+	AuthorAssociation string     `json:"authorAssociation"`
+	Author            *actorNode `json:"author"`
+	Labels            struct {
+		Nodes []Label `json:"nodes"`
+	} `json:"labels"`
+	ReactionGroups []reactionGroupNode `json:"reactionGroups"`
+	TimelineItems  struct {
+		Nodes []struct {
+			Typename  string    `json:"__typename"`
+			CreatedAt time.Time `json:"createdAt"`
+		} `json:"nodes"`
+	} `json:"timelineItems"`
+	Comments commentConnection `json:"comments"`
+}
+
+// FetchIssueThreads pages through the issues of a repo updated since the given
+// time, newest first like the REST listing, with their comments, reactions and
+// timeline nested in the same query.
+// States are GraphQL IssueState values such as "CLOSED".
+func (client *Client) FetchIssueThreads(repoFullname string, since time.Time, states ...string) ([]IssueThread, error) {
+	owner, name, found := strings.Cut(repoFullname, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid repo name %q", repoFullname)
+	}
+
+	req := graphql.NewRequest(issueThreadsQuery)
+	req.Var("owner", owner)
+	req.Var("name", name)
+	req.Var("since", since.UTC().Format(time.RFC3339))
+	if len(states) > 0 {
+		req.Var("states", states)
+	}
+
+	threads := []IssueThread{}
+
+	cursor := ""
+	for {
+		if cursor != "" {
+			req.Var("cursor", cursor)
+		}
+
+		var respData struct {
+			Repository struct {
+				Issues struct {
+					Nodes    []issueNode `json:"nodes"`
+					PageInfo pageInfo    `json:"pageInfo"`
+				} `json:"issues"`
+			} `json:"repository"`
+		}
+
+		if err := client.query(req, &respData); err != nil {
+			fmt.Println("Failed to fetch issues for", repoFullname, ":", err)
+			return nil, err
+		}
+
+		for _, node := range respData.Repository.Issues.Nodes {
+			thread, err := client.convertIssueNode(repoFullname, &node)
+			if err != nil {
+				return nil, err
+			}
+			threads = append(threads, *thread)
+		}
+
+		if !respData.Repository.Issues.PageInfo.HasNextPage {
+			break
+		}
+		cursor = respData.Repository.Issues.PageInfo.EndCursor
+	}
+
+	return threads, nil
+}
+
+func (client *Client) convertIssueNode(repoFullname string, node *issueNode) (*IssueThread, error) {
+	issueURL := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d", repoFullname, node.Number)
+
+	thread := &IssueThread{
+		Issue: Issue{
+			ID:        node.DatabaseID,
+			URL:       issueURL,
+			Number:    node.Number,
+			Title:     node.Title,
+			Body:      node.Body,
+			User:      convertActor(node.Author),
+			Labels:    node.Labels.Nodes,
+			State:     strings.ToLower(node.State),
+			Comments:  node.Comments.TotalCount,
+			CreatedAt: node.CreatedAt,
+			UpdatedAt: node.UpdatedAt,
+			Type:      node.AuthorAssociation,
+		},
+		Reactions: convertReactionGroups(node.ReactionGroups),
+	}
+
+	for _, item := range node.TimelineItems.Nodes {
+		thread.Timeline = append(thread.Timeline, TimelineItem{Type: item.Typename, CreatedAt: item.CreatedAt})
+	}
+
+	comments := node.Comments
+	for {
+		for _, comment := range comments.Nodes {
+			thread.Comments = append(thread.Comments, convertCommentNode(issueURL, &comment))
+		}
+
+		if !comments.PageInfo.HasNextPage {
+			break
+		}
+
+		// overflowing comment pages are fetched through the issue node
+		req := graphql.NewRequest(issueCommentsQuery)
+		req.Var("id", node.ID)
+		req.Var("cursor", comments.PageInfo.EndCursor)
+
+		var respData struct {
+			Node struct {
+				Comments commentConnection `json:"comments"`
+			} `json:"node"`
+		}
+
+		if err := client.query(req, &respData); err != nil {
+			fmt.Println("Failed to fetch comments for", repoFullname, ":", node.Number)
+			return nil, err
+		}
+		comments = respData.Node.Comments
+	}
+
+	return thread, nil
+}
+
+func convertCommentNode(issueURL string, node *commentNode) ThreadComment {
+	return ThreadComment{
+		Comment: Comment{
+			ID:        node.DatabaseID,
+			IssueURL:  issueURL,
+			Body:      node.Body,
+			User:      convertActor(node.Author),
+			CreatedAt: node.CreatedAt,
+			UpdatedAt: node.UpdatedAt,
+			Type:      node.AuthorAssociation,
+		},
+		Reactions: convertReactionGroups(node.ReactionGroups),
+	}
+}
+
+// convertActor matches the user objects the REST API returns: deleted accounts
+// become the ghost user and bot logins carry the [bot] suffix.
+func convertActor(actor *actorNode) User {
+	if actor == nil {
+		return User{ID: 10137, Login: "ghost"}
+	}
+
+	login := actor.Login
+	if actor.Typename == "Bot" {
+		login += "[bot]"
+	}
+
+	return User{ID: actor.DatabaseID, Login: login}
+}
+
+func convertReactionGroups(nodes []reactionGroupNode) []ReactionGroup {
+	var groups []ReactionGroup
+	for _, node := range nodes {
+		if node.Reactors.TotalCount > 0 {
+			groups = append(groups, ReactionGroup{Content: node.Content, Count: node.Reactors.TotalCount})
+		}
+	}
+	return groups
+}
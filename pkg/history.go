@@ -0,0 +1,113 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/machinebox/graphql"
+)
+
+// ErrHistoryUnavailable is returned when GraphQL cannot count the commits of a
+// repo, e.g. an empty repo without a default branch, so callers can fall back
+// to paging commits over REST.
+var ErrHistoryUnavailable = errors.New("commit history totalCount unavailable")
+
+type Interval struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Number of history connections aliased into one query.
+const historyBatchSize = 50
+
+// FetchCommitCounts counts the commits in each interval from the history of
+// ref, a branch or SHA, or of the default branch when ref is empty. It only
+// asks for totalCount so no commit is downloaded. Path optionally restricts
+// the count to a file or directory. Intervals are batched into one query
+// through aliases.
+func (client *Client) FetchCommitCounts(repoFullname string, ref string, path string, intervals []Interval) ([]int, error) {
+	owner, name, found := strings.Cut(repoFullname, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid repo name %q", repoFullname)
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	counts := make([]int, 0, len(intervals))
+	for start := 0; start < len(intervals); start += historyBatchSize {
+		end := start + historyBatchSize
+		if end > len(intervals) {
+			end = len(intervals)
+		}
+
+		req := graphql.NewRequest(commitCountsQuery(path != "", intervals[start:end]))
+		req.Var("owner", owner)
+		req.Var("name", name)
+		req.Var("ref", ref)
+		if path != "" {
+			req.Var("path", path)
+		}
+
+		var respData struct {
+			Repository struct {
+				Target *map[string]struct {
+					TotalCount *int `json:"totalCount"`
+				} `json:"target"`
+			} `json:"repository"`
+		}
+
+		if err := client.query(req, &respData); err != nil {
+			fmt.Println("Failed to fetch commit counts for", repoFullname, ":", err)
+			return nil, err
+		}
+
+		if respData.Repository.Target == nil {
+			return nil, ErrHistoryUnavailable
+		}
+
+		target := *respData.Repository.Target
+		for i := range intervals[start:end] {
+			history, ok := target[fmt.Sprintf("i%d", i)]
+			if !ok || history.TotalCount == nil {
+				return nil, ErrHistoryUnavailable
+			}
+			counts = append(counts, *history.TotalCount)
+		}
+	}
+
+	return counts, nil
+}
+
+func commitCountsQuery(withPath bool, intervals []Interval) string {
+	var histories strings.Builder
+	for i, interval := range intervals {
+		args := fmt.Sprintf(`since: "%s", until: "%s"`,
+			interval.Since.UTC().Format(time.RFC3339),
+			interval.Until.UTC().Format(time.RFC3339),
+		)
+		if withPath {
+			args += ", path: $path"
+		}
+		fmt.Fprintf(&histories, "\t\t\t\ti%d: history(%s) { totalCount }\n", i, args)
+	}
+
+	pathVar := ""
+	if withPath {
+		pathVar = ", $path: String!"
+	}
+
+	return fmt.Sprintf(`
+	query ($owner: String!, $name: String!, $ref: String!%s) {
+		repository(owner: $owner, name: $name) {
+			target: object(expression: $ref) {
+				... on Commit {
+%s				}
+			}
+		}
+	}
+`, pathVar, histories.String())
+}
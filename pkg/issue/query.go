@@ -2,7 +2,10 @@ package issue
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type IssueQuery struct {
@@ -45,6 +48,25 @@ func PerPage(value int) func(*IssueQuery) {
 	return setParam("per_page", fmt.Sprint(value))
 }
 
+// Since only keeps issues updated at or after the given time.
+func Since(value time.Time) func(*IssueQuery) {
+	return setParam("since", value.UTC().Format(time.RFC3339))
+}
+
+func Creator(login string) func(*IssueQuery) {
+	return setParam("creator", login)
+}
+
+func Mentioned(login string) func(*IssueQuery) {
+	return setParam("mentioned", login)
+}
+
+// Type filters by issue type name, or "none" and "*" for issues without or
+// with any type.
+func Type(value string) func(*IssueQuery) {
+	return setParam("type", value)
+}
+
 type QueryState struct {
 	value string
 }
@@ -65,10 +87,159 @@ func All() QueryState {
 	return QueryState{"all"}
 }
 
+type QuerySort struct {
+	value string
+}
+
+func Sort(value QuerySort) func(*IssueQuery) {
+	return setParam("sort", value.value)
+}
+
+func Created() QuerySort {
+	return QuerySort{"created"}
+}
+
+func Updated() QuerySort {
+	return QuerySort{"updated"}
+}
+
+func Comments() QuerySort {
+	return QuerySort{"comments"}
+}
+
+type QueryDirection struct {
+	value string
+}
+
+func Direction(value QueryDirection) func(*IssueQuery) {
+	return setParam("direction", value.value)
+}
+
+func Asc() QueryDirection {
+	return QueryDirection{"asc"}
+}
+
+func Desc() QueryDirection {
+	return QueryDirection{"desc"}
+}
+
+type QueryAssignee struct {
+	value string
+}
+
+func Assignee(value QueryAssignee) func(*IssueQuery) {
+	return setParam("assignee", value.value)
+}
+
+func AssignedTo(login string) QueryAssignee {
+	return QueryAssignee{login}
+}
+
+func Unassigned() QueryAssignee {
+	return QueryAssignee{"none"}
+}
+
+func AnyAssignee() QueryAssignee {
+	return QueryAssignee{"*"}
+}
+
+type QueryMilestone struct {
+	value string
+}
+
+func Milestone(value QueryMilestone) func(*IssueQuery) {
+	return setParam("milestone", value.value)
+}
+
+func MilestoneNumber(number int) QueryMilestone {
+	return QueryMilestone{fmt.Sprint(number)}
+}
+
+func NoMilestone() QueryMilestone {
+	return QueryMilestone{"none"}
+}
+
+func AnyMilestone() QueryMilestone {
+	return QueryMilestone{"*"}
+}
+
+// ToString encodes the query with keys in sorted order.
 func (issueQuery IssueQuery) ToString() string {
-	var pairs []string
-	for key, value := range issueQuery.internal {
-		pairs = append(pairs, key+"="+value)
+	return EncodeParams(issueQuery.internal)
+}
+
+// EncodeParams encodes query parameters with keys in sorted order, so the same
+// query always yields the same URL.
+func EncodeParams(params map[string]string) string {
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return values.Encode()
+}
+
+// ParseIssueQuery turns a query string, with or without the leading "?", back
+// into an IssueQuery. Unknown parameters and malformed values are errors.
+func ParseIssueQuery(raw string) (*IssueQuery, error) {
+	values, err := url.ParseQuery(strings.TrimPrefix(raw, "?"))
+	if err != nil {
+		return nil, err
+	}
+
+	issueQuery := NewIssueQuery()
+	for key, list := range values {
+		if len(list) != 1 {
+			return nil, fmt.Errorf("parameter %q given %d times", key, len(list))
+		}
+		value := list[0]
+
+		if err := validateParam(key, value); err != nil {
+			return nil, err
+		}
+		issueQuery.internal[key] = value
+	}
+
+	return issueQuery, nil
+}
+
+func validateParam(key string, value string) error {
+	oneOf := func(allowed ...string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s %q, expected one of %s", key, value, strings.Join(allowed, ", "))
+	}
+
+	switch key {
+	case "labels", "creator", "mentioned", "type", "assignee":
+		return nil
+	case "state":
+		return oneOf("open", "closed", "all")
+	case "sort":
+		return oneOf("created", "updated", "comments")
+	case "direction":
+		return oneOf("asc", "desc")
+	case "since":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("invalid since %q: %w", value, err)
+		}
+		return nil
+	case "page", "per_page":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", key, value, err)
+		}
+		return nil
+	case "milestone":
+		if value == "none" || value == "*" {
+			return nil
+		}
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid milestone %q, expected a number, none or *", value)
+		}
+		return nil
 	}
-	return strings.Join(pairs, "&")
+
+	return fmt.Errorf("unknown issue query parameter %q", key)
 }
@@ -0,0 +1,86 @@
+package issue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseIssueQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"empty", "", ""},
+		{"leading question mark", "?state=open", "state=open"},
+		{"sorted on output", "state=closed&page=2&labels=bug", "labels=bug&page=2&state=closed"},
+		{"labels with comma", "labels=bug%2Chelp+wanted", "labels=bug%2Chelp+wanted"},
+		{"since", "since=2016-01-01T00%3A00%3A00Z", "since=2016-01-01T00%3A00%3A00Z"},
+		{"milestone none", "milestone=none", "milestone=none"},
+		{"milestone any", "milestone=*", "milestone=%2A"},
+		{"milestone number", "milestone=3", "milestone=3"},
+		{"assignee", "assignee=octocat", "assignee=octocat"},
+		{"type", "type=Bug", "type=Bug"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := ParseIssueQuery(test.raw)
+			if err != nil {
+				t.Fatalf("ParseIssueQuery(%q) failed: %v", test.raw, err)
+			}
+			if got := query.ToString(); got != test.want {
+				t.Errorf("ParseIssueQuery(%q).ToString() = %q, want %q", test.raw, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseIssueQueryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"unknown parameter", "foo=bar"},
+		{"repeated parameter", "state=open&state=closed"},
+		{"invalid state", "state=merged"},
+		{"invalid sort", "sort=stars"},
+		{"invalid direction", "direction=up"},
+		{"invalid since", "since=2016-01-01"},
+		{"invalid page", "page=two"},
+		{"invalid per_page", "per_page=1.5"},
+		{"invalid milestone", "milestone=next"},
+		{"invalid escape", "labels=%zz"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseIssueQuery(test.raw); err == nil {
+				t.Errorf("ParseIssueQuery(%q) succeeded, want an error", test.raw)
+			}
+		})
+	}
+}
+
+func TestParseIssueQueryRoundTrip(t *testing.T) {
+	query := NewIssueQuery(
+		State(All()),
+		Sort(Updated()),
+		Direction(Asc()),
+		Since(time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		PerPage(100),
+		Page(3),
+		Labels("bug,help wanted"),
+		Assignee(Unassigned()),
+		Milestone(AnyMilestone()),
+		Creator("octocat"),
+	)
+
+	parsed, err := ParseIssueQuery(query.ToString())
+	if err != nil {
+		t.Fatalf("ParseIssueQuery(%q) failed: %v", query.ToString(), err)
+	}
+	if parsed.ToString() != query.ToString() {
+		t.Errorf("round trip gave %q, want %q", parsed.ToString(), query.ToString())
+	}
+}
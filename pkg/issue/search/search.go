@@ -0,0 +1,211 @@
+package search
+
+import "github-issue-data/pkg/repos"
+
+// SearchParams is an issue search. It shares the syntax of repos.SearchParams,
+// including repeated keys and negation, and is built on it.
+type SearchParams struct {
+	internal *repos.SearchParams
+}
+
+func NewSearchParams(options ...func(*SearchParams)) *SearchParams {
+	searchParams := &SearchParams{
+		internal: repos.NewSearchParams(),
+	}
+
+	for _, option := range options {
+		option(searchParams)
+	}
+
+	return searchParams
+}
+
+func (searchParams *SearchParams) Set(options ...func(*SearchParams)) {
+	for _, option := range options {
+		option(searchParams)
+	}
+}
+
+func (from *SearchParams) Copy() *SearchParams {
+	return &SearchParams{
+		internal: from.internal.Copy(),
+	}
+}
+
+func with(option func(*repos.SearchParams)) func(*SearchParams) {
+	return func(search *SearchParams) {
+		search.internal.Set(option)
+	}
+}
+
+func setParam(key string, value string) func(*SearchParams) {
+	return with(repos.Qualifier(key, value, false))
+}
+
+func addParam(key string, value string) func(*SearchParams) {
+	return with(repos.Qualifier(key, value, true))
+}
+
+// Not negates the qualifiers set by the given options, e.g.
+// Not(Label("wontfix")) yields -label:wontfix.
+func Not(options ...func(*SearchParams)) func(*SearchParams) {
+	return with(repos.Not(func(negated *repos.SearchParams) {
+		(&SearchParams{internal: negated}).Set(options...)
+	}))
+}
+
+func Query(value string) func(*SearchParams) {
+	return with(repos.Query(value))
+}
+
+type IsValue struct {
+	value string
+}
+
+func Is(value IsValue) func(*SearchParams) {
+	return setParam("is", value.value)
+}
+
+func Issue() IsValue       { return IsValue{"issue"} }
+func PullRequest() IsValue { return IsValue{"pr"} }
+
+type StateValue struct {
+	value string
+}
+
+func State(value StateValue) func(*SearchParams) {
+	return setParam("state", value.value)
+}
+
+func Open() StateValue   { return StateValue{"open"} }
+func Closed() StateValue { return StateValue{"closed"} }
+
+func Repo(fullName string) func(*SearchParams) {
+	return with(repos.Repo(fullName))
+}
+
+func Created(value repos.Time) func(*SearchParams) {
+	return with(repos.Created(value))
+}
+
+func ClosedAt(value repos.Time) func(*SearchParams) {
+	return setParam("closed", value.String())
+}
+
+func Comments(value repos.Int) func(*SearchParams) {
+	return setParam("comments", value.String())
+}
+
+// Label may be given more than once, for issues with every label.
+func Label(value string) func(*SearchParams) {
+	return addParam("label", value)
+}
+
+func Author(login string) func(*SearchParams) {
+	return setParam("author", login)
+}
+
+func Involves(login string) func(*SearchParams) {
+	return setParam("involves", login)
+}
+
+type NoValue struct {
+	value string
+}
+
+// No may be given more than once, e.g. for issues with neither a label nor a
+// milestone.
+func No(value NoValue) func(*SearchParams) {
+	return addParam("no", value.value)
+}
+
+func NoLabel() NoValue     { return NoValue{"label"} }
+func NoMilestone() NoValue { return NoValue{"milestone"} }
+func NoAssignee() NoValue  { return NoValue{"assignee"} }
+func NoProject() NoValue   { return NoValue{"project"} }
+
+type ReasonValue struct {
+	value string
+}
+
+func Reason(value ReasonValue) func(*SearchParams) {
+	return setParam("reason", value.value)
+}
+
+func Completed() ReasonValue  { return ReasonValue{"completed"} }
+func NotPlanned() ReasonValue { return ReasonValue{"not planned"} }
+
+// String renders the search as typed into the GitHub search box, in the same
+// canonical order as repos.SearchParams.
+func (searchParams SearchParams) String() string {
+	return searchParams.internal.String()
+}
+
+// ToString is the URL encoded form of the search, ready for the q parameter.
+func (searchParams SearchParams) ToString() string {
+	return searchParams.internal.ToString()
+}
+
+type Sort struct {
+	Value string
+}
+
+func SortByCreated() *Sort      { return &Sort{"created"} }
+func SortByUpdated() *Sort      { return &Sort{"updated"} }
+func SortByComments() *Sort     { return &Sort{"comments"} }
+func SortByReactions() *Sort    { return &Sort{"reactions"} }
+func SortByInteractions() *Sort { return &Sort{"interactions"} }
+
+type SearchIssuesParams struct {
+	Search  *SearchParams
+	PerPage int
+	Page    int
+	Sort    *Sort
+	Order   *repos.Order
+}
+
+func NewSearchIssuesParams(options ...func(*SearchIssuesParams)) *SearchIssuesParams {
+	searchIssuesParams := &SearchIssuesParams{
+		Search:  NewSearchParams(),
+		PerPage: 30,
+		Page:    1,
+		Sort:    nil,
+		Order:   nil,
+	}
+
+	for _, option := range options {
+		option(searchIssuesParams)
+	}
+
+	return searchIssuesParams
+}
+
+func SetSearchParams(searchParams *SearchParams) func(*SearchIssuesParams) {
+	return func(searchIssuesParams *SearchIssuesParams) {
+		searchIssuesParams.Search = searchParams
+	}
+}
+
+func SetPerPage(perPage int) func(*SearchIssuesParams) {
+	return func(searchIssuesParams *SearchIssuesParams) {
+		searchIssuesParams.PerPage = perPage
+	}
+}
+
+func SetPage(page int) func(*SearchIssuesParams) {
+	return func(searchIssuesParams *SearchIssuesParams) {
+		searchIssuesParams.Page = page
+	}
+}
+
+func SetSort(sort *Sort) func(*SearchIssuesParams) {
+	return func(searchIssuesParams *SearchIssuesParams) {
+		searchIssuesParams.Sort = sort
+	}
+}
+
+func SetOrder(order *repos.Order) func(*SearchIssuesParams) {
+	return func(searchIssuesParams *SearchIssuesParams) {
+		searchIssuesParams.Order = order
+	}
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github-issue-data/pkg/repos"
+)
+
+func TestSearchParams(t *testing.T) {
+	day := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		search *SearchParams
+		want   string
+	}{
+		{
+			"repo issues",
+			NewSearchParams(Repo("octo/cat"), Is(Issue()), State(Closed())),
+			"is:issue repo:octo/cat state:closed",
+		},
+		{
+			"several labels",
+			NewSearchParams(Label("bug"), Label("help wanted"), Label("bug")),
+			`label:bug label:"help wanted"`,
+		},
+		{
+			"negated labels and author",
+			NewSearchParams(Label("bug"), Not(Label("wontfix"), Author("dependabot[bot]"))),
+			"-author:dependabot[bot] label:bug -label:wontfix",
+		},
+		{
+			"single valued replaced",
+			NewSearchParams(State(Open()), State(Closed())),
+			"state:closed",
+		},
+		{
+			"several no",
+			NewSearchParams(No(NoLabel()), No(NoMilestone())),
+			"no:label no:milestone",
+		},
+		{
+			"free text first",
+			NewSearchParams(Reason(NotPlanned()), Query("crash on start"), Created(repos.Time{}.Min(day))),
+			`crash on start created:>=2016-01-01 reason:"not planned"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.search.String(); got != test.want {
+				t.Errorf("String() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchParamsCopy(t *testing.T) {
+	search := NewSearchParams(Label("bug"))
+	copied := search.Copy()
+	copied.Set(Label("docs"), Not(Author("octocat")))
+
+	if got, want := search.ToString(), "label%3Abug"; got != want {
+		t.Errorf("original changed to %q, want %q", got, want)
+	}
+	if got, want := copied.ToString(), "-author%3Aoctocat+label%3Abug+label%3Adocs"; got != want {
+		t.Errorf("ToString() = %q, want %q", got, want)
+	}
+}
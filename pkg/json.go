@@ -3,9 +3,9 @@ package github
 import "time"
 
 type Repo struct {
-	ID       int    `json:"id"`
+	ID       int    `json:"id" csv:",required"`
 	Name     string `json:"name"`
-	FullName string `json:"full_name"`
+	FullName string `json:"full_name" csv:",required"`
 	Stars    int    `json:"stargazers_count"`
 }
 
@@ -16,6 +16,7 @@ type Issue = struct {
 	Title       string    `json:"title"`
 	Body        string    `json:"body"`
 	User        User      `json:"user"`
+	Labels      []Label   `json:"labels"`
 	State       string    `json:"state"`
 	Comments    int       `json:"comments"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -24,13 +25,31 @@ type Issue = struct {
 	Type        string    `json:"author_association"`
 }
 
+type Label struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
 type User struct {
 	ID    int    `json:"id"`
 	Login string `json:"login"`
 }
 
+// UserProfile is the part of a user's profile needed to tell real accounts
+// from throwaway ones.
+type UserProfile struct {
+	ID          int       `json:"id"`
+	Login       string    `json:"login"`
+	CreatedAt   time.Time `json:"created_at"`
+	PublicRepos int       `json:"public_repos"`
+	Followers   int       `json:"followers"`
+	Following   int       `json:"following"`
+}
+
 type Comment struct {
 	ID        int       `json:"id"`
+	IssueURL  string    `json:"issue_url"`
 	Body      string    `json:"body"`
 	User      User      `json:"user"`
 	CreatedAt time.Time `json:"created_at"`
@@ -40,15 +59,48 @@ type Comment struct {
 
 type Star struct {
 	StarredAt time.Time `json:"starred_at"`
+	User      User      `json:"user"`
+}
+
+type GitActor struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type CommitFile struct {
+	Filename  string `json:"filename"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Changes   int    `json:"changes"`
 }
 
 type Commit struct {
+	SHA    string `json:"sha"`
 	Commit struct {
-		Author struct {
-			Date time.Time `json:"date"`
-		} `json:"author"`
-		Commiter struct {
-			Date time.Time `json:"date"`
-		} `json:"commiter"`
+		Author       GitActor `json:"author"`
+		Committer    GitActor `json:"committer"`
+		Message      string   `json:"message"`
+		Verification struct {
+			Verified bool   `json:"verified"`
+			Reason   string `json:"reason"`
+		} `json:"verification"`
 	} `json:"commit"`
+	// GitHub accounts linked to the git author and committer, nil when the
+	// email matches no account.
+	Author    *User `json:"author"`
+	Committer *User `json:"committer"`
+	Parents   []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+	// Only returned by the single commit endpoint.
+	Stats *struct {
+		Additions int `json:"additions"`
+		Deletions int `json:"deletions"`
+		Total     int `json:"total"`
+	} `json:"stats,omitempty"`
+	Files []CommitFile `json:"files,omitempty"`
+	// Set when the commit has more files than the API lists.
+	FilesTruncated bool `json:"-"`
 }
@@ -0,0 +1,106 @@
+package github
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// JSONLSink writes rows as JSON Lines to <path>.<Extension>, one object per
+// row with the nested structure of the row kept as is, where CSV flattens it.
+// Extensions ending in .gz or .zst are compressed.
+type JSONLSink struct {
+	Extension  string
+	rowType    reflect.Type
+	file       *os.File
+	buffer     *bufio.Writer
+	compressor io.WriteCloser
+	encoder    *json.Encoder
+}
+
+// flushWriter is a compressor that can push out what it has buffered.
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func (sink *JSONLSink) Open(path string, rowType reflect.Type) error {
+	return sink.open(path, rowType, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+}
+
+// Append adds to an existing file. Compressed files get another gzip member or
+// zstd frame, which decoders read on as one stream.
+func (sink *JSONLSink) Append(path string, rowType reflect.Type) error {
+	return sink.open(path, rowType, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+}
+
+func (sink *JSONLSink) open(path string, rowType reflect.Type, flag int) error {
+	filename := path + "." + sink.Extension
+	file, err := os.OpenFile(filename, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	*sink = JSONLSink{Extension: sink.Extension, rowType: rowType, file: file}
+
+	var out io.Writer = file
+	switch {
+	case strings.HasSuffix(filename, ".gz"):
+		sink.compressor = gzip.NewWriter(file)
+		out = sink.compressor
+	case strings.HasSuffix(filename, ".zst"):
+		sink.compressor, err = zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		out = sink.compressor
+	}
+
+	sink.buffer = bufio.NewWriter(out)
+	sink.encoder = json.NewEncoder(sink.buffer)
+	// leave <, > and & in comment bodies unescaped
+	sink.encoder.SetEscapeHTML(false)
+
+	return nil
+}
+
+func (sink *JSONLSink) Write(row any) error {
+	if err := checkRowType(row, sink.rowType); err != nil {
+		return err
+	}
+
+	return sink.encoder.Encode(row)
+}
+
+// Flush pushes rows through the compressor, so the rows written before a
+// crash can be read back.
+func (sink *JSONLSink) Flush() error {
+	if err := sink.buffer.Flush(); err != nil {
+		return err
+	}
+
+	if compressor, ok := sink.compressor.(flushWriter); ok {
+		return compressor.Flush()
+	}
+	return nil
+}
+
+func (sink *JSONLSink) Close() error {
+	err := sink.buffer.Flush()
+	if sink.compressor != nil {
+		if closeErr := sink.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := sink.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
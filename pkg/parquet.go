@@ -0,0 +1,267 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+// ParquetSink writes rows into <path>.parquet with the same columns CSVSink
+// would, typed instead of formatted and sorted by name. Unlike CSV the footer
+// is only written on Close, so a file is unreadable until then.
+type ParquetSink struct {
+	config  *ParquetConfig
+	file    *os.File
+	writer  *parquet.Writer
+	rowType reflect.Type
+	columns []parquetColumn
+}
+
+type parquetColumn struct {
+	csvColumn
+	leaf     parquet.LeafColumn
+	optional bool
+}
+
+type ParquetConfig struct {
+	compression  compress.Codec
+	rowGroupSize int64
+}
+
+// ParquetCompression picks the codec, one of zstd, snappy, gzip or none.
+// Datasets are compressed with zstd by default.
+func ParquetCompression(name string) func(*ParquetConfig) {
+	return func(config *ParquetConfig) {
+		switch name {
+		case "snappy":
+			config.compression = &parquet.Snappy
+		case "gzip":
+			config.compression = &parquet.Gzip
+		case "none":
+			config.compression = &parquet.Uncompressed
+		default:
+			config.compression = &parquet.Zstd
+		}
+	}
+}
+
+// ParquetRowGroupSize sets how many rows go in a row group.
+func ParquetRowGroupSize(rows int64) func(*ParquetConfig) {
+	return func(config *ParquetConfig) {
+		config.rowGroupSize = rows
+	}
+}
+
+func NewParquetSink(options ...func(*ParquetConfig)) *ParquetSink {
+	config := &ParquetConfig{
+		compression:  &parquet.Zstd,
+		rowGroupSize: 128 * 1024,
+	}
+
+	for _, option := range options {
+		option(config)
+	}
+
+	return &ParquetSink{config: config}
+}
+
+func (sink *ParquetSink) Open(path string, rowType reflect.Type) error {
+	columns, err := csvColumns(rowType)
+	if err != nil {
+		return err
+	}
+
+	group := parquet.Group{}
+	optional := make([]bool, len(columns))
+	for i, column := range columns {
+		var node parquet.Node
+		node, optional[i] = parquetNode(rowType, column.index)
+		group[column.name] = node
+	}
+	schema := parquet.NewSchema(rowType.Name(), group)
+
+	// the group sorts columns by name, so look up where each one ended up
+	parquetColumns := make([]parquetColumn, len(columns))
+	for i, column := range columns {
+		leaf, _ := schema.Lookup(column.name)
+		parquetColumns[i] = parquetColumn{csvColumn: column, leaf: leaf, optional: optional[i]}
+	}
+
+	file, err := os.Create(path + ".parquet")
+	if err != nil {
+		return err
+	}
+
+	writer := parquet.NewWriter(file,
+		schema,
+		parquet.Compression(sink.config.compression),
+		parquet.MaxRowsPerRowGroup(sink.config.rowGroupSize),
+	)
+
+	*sink = ParquetSink{
+		config:  sink.config,
+		file:    file,
+		writer:  writer,
+		rowType: rowType,
+		columns: parquetColumns,
+	}
+	return nil
+}
+
+// Write buffers a row, rows are written out a row group at a time.
+func (sink *ParquetSink) Write(row any) error {
+	if err := checkRowType(row, sink.rowType); err != nil {
+		return err
+	}
+
+	parquetRow, err := sink.encode(reflect.ValueOf(row))
+	if err != nil {
+		return err
+	}
+
+	_, err = sink.writer.WriteRows([]parquet.Row{parquetRow})
+	return err
+}
+
+// Flush does nothing, the file is only readable once closed.
+func (sink *ParquetSink) Flush() error {
+	return nil
+}
+
+func (sink *ParquetSink) Close() error {
+	if err := sink.writer.Close(); err != nil {
+		sink.file.Close()
+		return err
+	}
+
+	return sink.file.Close()
+}
+
+func (sink *ParquetSink) encode(v reflect.Value) (parquet.Row, error) {
+	addressable := addressableCopy(v)
+
+	row := make(parquet.Row, len(sink.columns))
+	for _, column := range sink.columns {
+		value := parquet.NullValue()
+
+		field, ok := csvField(addressable, column.index)
+		if ok {
+			var err error
+			value, err = parquetValue(field, column.optional)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column.name, err)
+			}
+		}
+
+		definitionLevel := 0
+		if !value.IsNull() {
+			definitionLevel = column.leaf.MaxDefinitionLevel
+		}
+
+		row[column.leaf.ColumnIndex] = value.Level(0, definitionLevel, column.leaf.ColumnIndex)
+	}
+
+	return row, nil
+}
+
+// parquetNode picks the logical type of a column and whether it can be null,
+// which is the case behind any pointer and for times, written as null when
+// zero as in CSV.
+func parquetNode(t reflect.Type, index []int) (parquet.Node, bool) {
+	optional := false
+
+	field := reflect.StructField{Type: t}
+	for _, i := range index {
+		if field.Type.Kind() == reflect.Pointer {
+			optional = true
+			field.Type = field.Type.Elem()
+		}
+		field = field.Type.Field(i)
+	}
+
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Pointer {
+		optional = true
+		fieldType = fieldType.Elem()
+	}
+
+	var node parquet.Node
+	switch {
+	case fieldType == timeType:
+		optional = true
+		node = parquet.Timestamp(parquet.Millisecond)
+	case isMarshaler(fieldType):
+		node = parquet.String()
+	default:
+		switch fieldType.Kind() {
+		case reflect.Bool:
+			node = parquet.Leaf(parquet.BooleanType)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			node = parquet.Int(64)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			node = parquet.Uint(64)
+		case reflect.Float32, reflect.Float64:
+			node = parquet.Leaf(parquet.DoubleType)
+		case reflect.String:
+			node = parquet.String()
+		default:
+			// formatted as in CSV, nil slices and maps are null
+			optional = true
+			node = parquet.String()
+		}
+	}
+
+	if optional {
+		return parquet.Optional(node), true
+	}
+	return node, false
+}
+
+func isMarshaler(t reflect.Type) bool {
+	pointer := reflect.PointerTo(t)
+	return pointer.Implements(csvMarshalerType) || pointer.Implements(textMarshalerType)
+}
+
+func parquetValue(v reflect.Value, optional bool) (parquet.Value, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return parquet.NullValue(), nil
+		}
+		v = v.Elem()
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return parquet.NullValue(), nil
+		}
+		return parquet.Int64Value(t.UnixMilli()), nil
+	}
+
+	if !isMarshaler(v.Type()) {
+		switch v.Kind() {
+		case reflect.Bool:
+			return parquet.BooleanValue(v.Bool()), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return parquet.Int64Value(v.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return parquet.Int64Value(int64(v.Uint())), nil
+		case reflect.Float32, reflect.Float64:
+			return parquet.DoubleValue(v.Float()), nil
+		case reflect.String:
+			return parquet.ByteArrayValue([]byte(v.String())), nil
+		}
+	}
+
+	value, err := formatCSVValue(v)
+	if err != nil {
+		return parquet.NullValue(), err
+	}
+	if value == "" && optional {
+		return parquet.NullValue(), nil
+	}
+	return parquet.ByteArrayValue([]byte(value)), nil
+}
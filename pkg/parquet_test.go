@@ -0,0 +1,141 @@
+package github
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows")
+	writeDataset[csvTestRow](t, OpenSink, "parquet", path)
+
+	file, err := os.Open(path + ".parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parquetFile, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		column   string
+		kind     parquet.Kind
+		logical  string
+		optional bool
+	}{
+		{"source", parquet.ByteArray, "STRING", false},
+		{"id", parquet.Int64, "INT(64,true)", false},
+		{"title", parquet.ByteArray, "STRING", false},
+		{"score", parquet.Double, "", false},
+		{"draft", parquet.Boolean, "", false},
+		{"user.id", parquet.Int64, "INT(64,true)", false},
+		{"user.login", parquet.ByteArray, "STRING", false},
+		{"assignee.id", parquet.Int64, "INT(64,true)", true},
+		{"assignee.login", parquet.ByteArray, "STRING", true},
+		{"created_at", parquet.Int64, "TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)", true},
+		{"level", parquet.ByteArray, "STRING", false},
+		{"labels", parquet.ByteArray, "STRING", true},
+	}
+
+	schema := parquetFile.Schema()
+	for _, test := range tests {
+		t.Run(test.column, func(t *testing.T) {
+			leaf, ok := schema.Lookup(test.column)
+			if !ok {
+				t.Fatalf("no column %s in %s", test.column, schema)
+			}
+
+			node := leaf.Node
+			if node.Type().Kind() != test.kind {
+				t.Errorf("kind = %s, want %s", node.Type().Kind(), test.kind)
+			}
+			logical := ""
+			if logicalType := node.Type().LogicalType(); logicalType != nil {
+				logical = logicalType.String()
+			}
+			if logical != test.logical {
+				t.Errorf("logical type = %s, want %s", logical, test.logical)
+			}
+			if node.Optional() != test.optional {
+				t.Errorf("optional = %v, want %v", node.Optional(), test.optional)
+			}
+		})
+	}
+
+	if columns := len(schema.Columns()); columns != len(tests) {
+		t.Errorf("%d columns, want %d", columns, len(tests))
+	}
+}
+
+func TestParquetRows(t *testing.T) {
+	rows := []csvTestRow{
+		{ID: 1},
+		{
+			ID:        2,
+			Title:     "title",
+			Assignee:  &csvTestUser{ID: 4, Login: "hubot"},
+			CreatedAt: time.Date(2016, time.January, 2, 3, 4, 5, 0, time.UTC),
+			Level:     2,
+			Labels:    []string{"bug"},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "rows")
+	writeDataset(t, OpenSink, "parquet", path, rows...)
+
+	file, err := os.Open(path + ".parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+
+	read := make([]parquet.Row, len(rows)+1)
+	n, err := reader.ReadRows(read)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if n != len(rows) {
+		t.Fatalf("read %d rows, want %d", n, len(rows))
+	}
+
+	tests := []struct {
+		row    int
+		column string
+		want   parquet.Value
+	}{
+		{0, "id", parquet.Int64Value(1)},
+		{0, "assignee.id", parquet.NullValue()},
+		{0, "created_at", parquet.NullValue()},
+		{0, "labels", parquet.NullValue()},
+		{0, "level", parquet.ByteArrayValue(nil)},
+		{1, "id", parquet.Int64Value(2)},
+		{1, "title", parquet.ByteArrayValue([]byte("title"))},
+		{1, "assignee.id", parquet.Int64Value(4)},
+		{1, "created_at", parquet.Int64Value(rows[1].CreatedAt.UnixMilli())},
+		{1, "level", parquet.ByteArrayValue([]byte("**"))},
+		{1, "labels", parquet.ByteArrayValue([]byte("[bug]"))},
+	}
+
+	for _, test := range tests {
+		leaf, _ := reader.Schema().Lookup(test.column)
+		got := read[test.row][leaf.ColumnIndex]
+		if got.IsNull() != test.want.IsNull() || (!got.IsNull() && got.String() != test.want.String()) {
+			t.Errorf("row %d column %s = %v, want %v", test.row, test.column, got, test.want)
+		}
+	}
+}
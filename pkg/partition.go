@@ -0,0 +1,139 @@
+package github
+
+import (
+	"fmt"
+	"time"
+
+	"github-issue-data/pkg/repos"
+)
+
+// PartitionReport records how completely one partition of a repo search was
+// fetched, so the manifest can show the population is whole.
+type PartitionReport struct {
+	Query           string `json:"query"`
+	Search          string `json:"search"`
+	TotalCount      int    `json:"total_count"`
+	Fetched         int    `json:"fetched"`
+	Retries         int    `json:"retries"`
+	IncompletePages int    `json:"incomplete_pages"`
+	Complete        bool   `json:"complete"`
+}
+
+// FetchPartitionedRepos fetches every repo matching the search, narrowing it
+// along the splits until each partition fits under the search result cap.
+// Repos are deduplicated by ID; the reported total count of the search is
+// returned alongside for comparison.
+//
+// With maxRetries above zero, pages flagged incomplete_results are requested
+// again with backoff, and partitions that stay incomplete are narrowed further
+// and fetched again. With zero, incomplete pages are only reported.
+func (client *Client) FetchPartitionedRepos(searchParams *repos.SearchParams, maxRetries int, splits ...repos.Split) ([]Repo, int, []PartitionReport, error) {
+	count := func(search *repos.SearchParams) (int, error) {
+		_, totalCount, _, err := client.FetchRepos(repos.NewFetchReposParams(
+			repos.SetSearchParams(search),
+			repos.SetPerPage(1),
+		))
+		return totalCount, err
+	}
+
+	totalCount, err := count(searchParams)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	partitions, err := repos.PartitionSearch(searchParams, count, splits...)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	fetched := make(map[int]bool)
+	population := []Repo{}
+	reports := []PartitionReport{}
+
+	for len(partitions) > 0 {
+		partition := partitions[0]
+		partitions = partitions[1:]
+
+		items, report, err := client.fetchPartition(partition, maxRetries)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+
+		if report.IncompletePages > 0 && maxRetries > 0 {
+			narrowed, narrowable, err := partition.Narrow(count)
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			if narrowable {
+				fmt.Println("[WARNING] narrowing incomplete partition", report.Search)
+				partitions = append(narrowed, partitions...)
+				continue
+			}
+		}
+
+		for _, repo := range items {
+			if fetched[repo.ID] {
+				continue
+			}
+
+			population = append(population, repo)
+			fetched[repo.ID] = true
+		}
+		reports = append(reports, *report)
+
+		fmt.Println("Partitions fetched:", len(reports), "/", len(reports)+len(partitions), "| Repos:", len(population), "/", totalCount)
+	}
+
+	return population, totalCount, reports, nil
+}
+
+func (client *Client) fetchPartition(partition repos.Partition, maxRetries int) ([]Repo, *PartitionReport, error) {
+	report := &PartitionReport{
+		Search:     partition.Search.String(),
+		TotalCount: partition.TotalCount,
+	}
+
+	items := []Repo{}
+
+	perPage := 100
+	for page := 1; (page-1)*perPage < partition.TotalCount && page*perPage <= repos.ResultCap; page++ {
+		var pageItems []Repo
+		for attempt := 0; ; attempt++ {
+			var incomplete bool
+			var err error
+			pageItems, _, incomplete, err = client.FetchRepos(repos.NewFetchReposParams(
+				repos.SetSearchParams(partition.Search),
+				repos.SetPage(page),
+				repos.SetPerPage(perPage),
+				repos.SetSort(repos.SortByStars()),
+				repos.SetOrder(repos.Asc()),
+			))
+			if err != nil {
+				fmt.Println("Error on fetching batch.\n[ERROR] -", err)
+				return nil, nil, err
+			}
+
+			if !incomplete {
+				break
+			}
+
+			if attempt == maxRetries {
+				fmt.Println("[WARNING] incomplete page.")
+				report.IncompletePages++
+				break
+			}
+
+			report.Retries++
+			backoffDuration := time.Duration((attempt+1)*(attempt+1)) * time.Second
+			fmt.Printf("Incomplete page, waiting for %s before retrying...\n", backoffDuration)
+			time.Sleep(backoffDuration)
+		}
+
+		items = append(items, pageItems...)
+	}
+
+	report.Fetched = len(items)
+	report.Complete = report.IncompletePages == 0 && report.Fetched >= report.TotalCount
+
+	return items, report, nil
+}
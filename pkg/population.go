@@ -0,0 +1,65 @@
+package github
+
+import (
+	"fmt"
+
+	"github-issue-data/pkg/repos"
+)
+
+// PopulationRepo is a repo in a population along with the names of the
+// searches that matched it.
+type PopulationRepo struct {
+	Repo
+	Matched []string
+}
+
+// FetchPopulation fetches every search the population is built from, tags each
+// repo with the searches that matched it and keeps the repos the population
+// contains, deduplicated by ID. Splits gives the ranges to partition each
+// search along.
+func (client *Client) FetchPopulation(population *repos.Population, maxRetries int, splits func(*repos.SearchParams) ([]repos.Split, error)) ([]PopulationRepo, []PartitionReport, error) {
+	order := []int{}
+	found := make(map[int]*PopulationRepo)
+	matched := make(map[int]map[string]bool)
+	reports := []PartitionReport{}
+
+	for _, search := range population.Searches() {
+		searchSplits, err := splits(search.Search)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		items, totalCount, searchReports, err := client.FetchPartitionedRepos(search.Search, maxRetries, searchSplits...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fmt.Println("Search", search.Name, "matched", len(items), "/", totalCount, "repos")
+
+		for _, report := range searchReports {
+			report.Query = search.Name
+			reports = append(reports, report)
+		}
+
+		for _, repo := range items {
+			if _, ok := found[repo.ID]; !ok {
+				order = append(order, repo.ID)
+				found[repo.ID] = &PopulationRepo{Repo: repo}
+				matched[repo.ID] = make(map[string]bool)
+			}
+			found[repo.ID].Matched = append(found[repo.ID].Matched, search.Name)
+			matched[repo.ID][search.Name] = true
+		}
+	}
+
+	members := []PopulationRepo{}
+	for _, id := range order {
+		if population.Contains(matched[id]) {
+			members = append(members, *found[id])
+		}
+	}
+
+	fmt.Println("Population", population.String(), "has", len(members), "repos")
+
+	return members, reports, nil
+}
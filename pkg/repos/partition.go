@@ -0,0 +1,253 @@
+package repos
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResultCap is the number of results the search API returns for a single
+// search, however many it reports in total_count.
+const ResultCap = 1000
+
+// Split is a range of one qualifier that a search can be narrowed along.
+type Split struct {
+	key      string
+	from, to int64
+	middle   func(from, to int64) int64
+	format   func(from, to int64) string
+	parse    func(value string) (int64, error)
+}
+
+// apply replaces the range the search has for the key, keeping negated terms
+// such as -stars:10..20 that exclude part of it.
+func (split Split) apply(search *SearchParams) {
+	kept := search.internal[:0]
+	for _, existing := range search.internal {
+		if existing.key != split.key || existing.negated {
+			kept = append(kept, existing)
+		}
+	}
+	search.internal = append(kept, qualifier{key: split.key, value: split.format(split.from, split.to)})
+}
+
+// intersect narrows the split to the range the search already has for its
+// key, so splitting never widens a search. It returns false when the two do
+// not overlap.
+func (split Split) intersect(search *SearchParams) (Split, bool, error) {
+	for _, existing := range search.internal {
+		if existing.key != split.key || existing.negated {
+			continue
+		}
+
+		from, to, err := parseRange(existing.value, split.parse)
+		if err != nil {
+			return split, false, fmt.Errorf("%s:%s: %w", existing.key, existing.value, err)
+		}
+		split.from = max(split.from, from)
+		split.to = min(split.to, to)
+	}
+
+	return split, split.from <= split.to, nil
+}
+
+// parseRange reads a qualifier range such as 10..50, *..50, >=10, <50 or 10
+// into inclusive bounds.
+func parseRange(value string, parse func(string) (int64, error)) (int64, int64, error) {
+	from, to := int64(math.MinInt64), int64(math.MaxInt64)
+
+	var err error
+	switch {
+	case strings.Contains(value, ".."):
+		lower, upper, _ := strings.Cut(value, "..")
+		if lower != "*" {
+			from, err = parse(lower)
+		}
+		if err == nil && upper != "*" {
+			to, err = parse(upper)
+		}
+	case strings.HasPrefix(value, ">="):
+		from, err = parse(value[2:])
+	case strings.HasPrefix(value, ">"):
+		// a date with a time may still include part of its day
+		from, err = parse(value[1:])
+		if !strings.Contains(value, "T") {
+			from++
+		}
+	case strings.HasPrefix(value, "<="):
+		to, err = parse(value[2:])
+	case strings.HasPrefix(value, "<"):
+		to, err = parse(value[1:])
+		if !strings.Contains(value, "T") {
+			to--
+		}
+	default:
+		from, err = parse(strings.TrimPrefix(value, "="))
+		to = from
+	}
+
+	return from, to, err
+}
+
+// halve splits the range into two that do not overlap and leave no gap, so no
+// repo on a boundary is lost or counted twice.
+func (split Split) halve() (Split, Split) {
+	mid := split.middle(split.from, split.to)
+	lower, upper := split, split
+	lower.to = mid
+	upper.from = mid + 1
+	return lower, upper
+}
+
+// StarsSplit narrows by star count. Stars are heavy tailed, so ranges are
+// halved at their geometric mean.
+func StarsSplit(min, max int) Split {
+	return Split{
+		key:  "stars",
+		from: int64(min),
+		to:   int64(max),
+		middle: func(from, to int64) int64 {
+			mid := int64(math.Sqrt(float64(from+1) * float64(to+1)))
+			if mid <= from {
+				return from
+			}
+			if mid >= to {
+				return to - 1
+			}
+			return mid
+		},
+		format: func(from, to int64) string {
+			return Int{}.Range(int(from), int(to)).value
+		},
+		parse: func(value string) (int64, error) {
+			return strconv.ParseInt(value, 10, 64)
+		},
+	}
+}
+
+func CreatedSplit(from, to time.Time) Split {
+	return dateSplit("created", from, to)
+}
+
+func PushedSplit(from, to time.Time) Split {
+	return dateSplit("pushed", from, to)
+}
+
+// dateSplit narrows by days, counted from the unix epoch.
+func dateSplit(key string, from, to time.Time) Split {
+	day := int64(24 * time.Hour / time.Second)
+	toDate := func(days int64) time.Time {
+		return time.Unix(days*day, 0).UTC()
+	}
+
+	return Split{
+		key:  key,
+		from: from.Unix() / day,
+		to:   to.Unix() / day,
+		middle: func(from, to int64) int64 {
+			return from + (to-from)/2
+		},
+		format: func(from, to int64) string {
+			return Time{}.Range(toDate(from), toDate(to)).value
+		},
+		// dates may carry a time, which is dropped as ranges are in days
+		parse: func(value string) (int64, error) {
+			date, err := time.Parse("2006-01-02", value[:min(len(value), 10)])
+			if err != nil {
+				return 0, err
+			}
+			return date.Unix() / day, nil
+		},
+	}
+}
+
+type Partition struct {
+	Search     *SearchParams
+	TotalCount int
+	base       *SearchParams
+	splits     []Split
+}
+
+// Narrow partitions this partition again with its first splittable range
+// halved, for when a partition under the cap still returns incomplete results.
+// It returns false once no range can be split any further.
+func (partition Partition) Narrow(count CountFunc) ([]Partition, bool, error) {
+	for i, split := range partition.splits {
+		if split.from >= split.to {
+			continue
+		}
+
+		lower, upper := split.halve()
+
+		lowerSplits := append([]Split{}, partition.splits...)
+		lowerSplits[i] = lower
+		partitions, err := PartitionSearch(partition.base, count, lowerSplits...)
+		if err != nil {
+			return nil, false, err
+		}
+
+		upperSplits := append([]Split{}, partition.splits...)
+		upperSplits[i] = upper
+		upperPartitions, err := PartitionSearch(partition.base, count, upperSplits...)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return append(partitions, upperPartitions...), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// CountFunc reports the total_count of a search.
+type CountFunc func(search *SearchParams) (int, error)
+
+// PartitionSearch narrows the search along the splits, in the order given,
+// until every partition holds at most ResultCap results. Splits only cover
+// the part of their range the search already asks for. The partitions are
+// disjoint and together cover the whole search. Empty partitions are dropped.
+func PartitionSearch(search *SearchParams, count CountFunc, splits ...Split) ([]Partition, error) {
+	splits = append([]Split{}, splits...)
+	for i, split := range splits {
+		intersected, overlaps, err := split.intersect(search)
+		if err != nil {
+			return nil, err
+		}
+		if !overlaps {
+			return nil, nil
+		}
+		splits[i] = intersected
+	}
+
+	narrowed := search.Copy()
+	for _, split := range splits {
+		split.apply(narrowed)
+	}
+
+	totalCount, err := count(narrowed)
+	if err != nil {
+		return nil, err
+	}
+
+	if totalCount == 0 {
+		return nil, nil
+	}
+
+	partition := Partition{Search: narrowed, TotalCount: totalCount, base: search, splits: splits}
+	if totalCount <= ResultCap {
+		return []Partition{partition}, nil
+	}
+
+	partitions, narrowable, err := partition.Narrow(count)
+	if err != nil {
+		return nil, err
+	}
+	if narrowable {
+		return partitions, nil
+	}
+
+	fmt.Println("[WARNING] cannot narrow", narrowed.String(), "below", ResultCap, "results, total count:", totalCount)
+	return []Partition{partition}, nil
+}
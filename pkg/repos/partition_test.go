@@ -0,0 +1,99 @@
+package repos
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	split := StarsSplit(0, 1000)
+
+	tests := []struct {
+		value    string
+		from, to int64
+	}{
+		{"10..50", 10, 50},
+		{"*..50", math.MinInt64, 50},
+		{"10..*", 10, math.MaxInt64},
+		{">=10", 10, math.MaxInt64},
+		{">10", 11, math.MaxInt64},
+		{"<=50", math.MinInt64, 50},
+		{"<50", math.MinInt64, 49},
+		{"10", 10, 10},
+		{"=10", 10, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			from, to, err := parseRange(test.value, split.parse)
+			if err != nil {
+				t.Fatalf("parseRange(%q) failed: %v", test.value, err)
+			}
+			if from != test.from || to != test.to {
+				t.Errorf("parseRange(%q) = %d, %d, want %d, %d", test.value, from, to, test.from, test.to)
+			}
+		})
+	}
+}
+
+func TestSplitIntersect(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  *SearchParams
+		want    string
+		overlap bool
+	}{
+		{"no range", NewSearchParams(Language("go")), "language:go stars:0..1000", true},
+		{"narrower", NewSearchParams(Stars(Int{}.Range(100, 200))), "stars:100..200", true},
+		{"lower bound", NewSearchParams(Stars(Int{}.Min(500))), "stars:500..1000", true},
+		{"disjoint", NewSearchParams(Stars(Int{}.Min(2000))), "", false},
+		{"negated kept", NewSearchParams(Not(Stars(Int{}.Range(10, 20)))), "stars:0..1000 -stars:10..20", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			split, overlap, err := StarsSplit(0, 1000).intersect(test.search)
+			if err != nil {
+				t.Fatalf("intersect(%q) failed: %v", test.search, err)
+			}
+			if overlap != test.overlap {
+				t.Fatalf("intersect(%q) overlap = %v, want %v", test.search, overlap, test.overlap)
+			}
+			if !overlap {
+				return
+			}
+
+			search := test.search.Copy()
+			split.apply(search)
+			if got := search.String(); got != test.want {
+				t.Errorf("intersect(%q) = %q, want %q", test.search, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDateSplitIntersect(t *testing.T) {
+	split := CreatedSplit(
+		time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2019, time.December, 31, 0, 0, 0, 0, time.UTC),
+	)
+	search := NewSearchParams(Created(Time{}.Min(time.Date(2018, time.June, 1, 12, 0, 0, 0, time.UTC))))
+
+	split, overlap, err := split.intersect(search)
+	if err != nil || !overlap {
+		t.Fatalf("intersect(%q) = %v, %v", search, overlap, err)
+	}
+
+	split.apply(search)
+	if got, want := search.String(), "created:2018-06-01..2019-12-31"; got != want {
+		t.Errorf("intersect gave %q, want %q", got, want)
+	}
+}
+
+func TestSplitHalve(t *testing.T) {
+	lower, upper := StarsSplit(100, 10000).halve()
+	if lower.from != 100 || upper.to != 10000 || upper.from != lower.to+1 {
+		t.Errorf("halve gave %d..%d and %d..%d", lower.from, lower.to, upper.from, upper.to)
+	}
+}
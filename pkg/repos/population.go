@@ -0,0 +1,79 @@
+package repos
+
+import "fmt"
+
+// Population is a set of repos defined by named searches combined with union,
+// intersection and difference.
+type Population struct {
+	name        string
+	search      *SearchParams
+	op          string
+	left, right *Population
+}
+
+type NamedSearch struct {
+	Name   string
+	Search *SearchParams
+}
+
+// Match is the population of repos matching a single search. The name tags
+// the repos it matched in the output.
+func Match(name string, search *SearchParams) *Population {
+	return &Population{name: name, search: search}
+}
+
+func (population *Population) Union(other *Population) *Population {
+	return &Population{op: "|", left: population, right: other}
+}
+
+func (population *Population) Intersect(other *Population) *Population {
+	return &Population{op: "&", left: population, right: other}
+}
+
+func (population *Population) Minus(other *Population) *Population {
+	return &Population{op: "-", left: population, right: other}
+}
+
+// Searches lists every search the population is built from, once each, in the
+// order they appear.
+func (population *Population) Searches() []NamedSearch {
+	if population.op == "" {
+		return []NamedSearch{{Name: population.name, Search: population.search}}
+	}
+
+	searches := population.left.Searches()
+	for _, search := range population.right.Searches() {
+		seen := false
+		for _, existing := range searches {
+			if existing.Name == search.Name {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			searches = append(searches, search)
+		}
+	}
+	return searches
+}
+
+// Contains reports whether a repo matched by the named searches belongs to the
+// population.
+func (population *Population) Contains(matched map[string]bool) bool {
+	switch population.op {
+	case "|":
+		return population.left.Contains(matched) || population.right.Contains(matched)
+	case "&":
+		return population.left.Contains(matched) && population.right.Contains(matched)
+	case "-":
+		return population.left.Contains(matched) && !population.right.Contains(matched)
+	}
+	return matched[population.name]
+}
+
+func (population *Population) String() string {
+	if population.op == "" {
+		return population.name
+	}
+	return fmt.Sprintf("(%s %s %s)", population.left, population.op, population.right)
+}
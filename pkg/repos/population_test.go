@@ -0,0 +1,47 @@
+package repos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPopulation(t *testing.T) {
+	golang := Match("go", NewSearchParams(Language("go")))
+	rust := Match("rust", NewSearchParams(Language("rust")))
+	forks := Match("forks", NewSearchParams(Fork(true)))
+	population := golang.Union(rust).Minus(forks.Intersect(golang))
+
+	if got, want := population.String(), "((go | rust) - (forks & go))"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	var names []string
+	for _, search := range population.Searches() {
+		names = append(names, search.Name)
+	}
+	if want := []string{"go", "rust", "forks"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Searches() = %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		matched []string
+		want    bool
+	}{
+		{[]string{"go"}, true},
+		{[]string{"rust"}, true},
+		{[]string{"go", "forks"}, false},
+		{[]string{"rust", "forks"}, true},
+		{[]string{"forks"}, false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		matched := map[string]bool{}
+		for _, name := range test.matched {
+			matched[name] = true
+		}
+		if got := population.Contains(matched); got != test.want {
+			t.Errorf("Contains(%v) = %v, want %v", test.matched, got, test.want)
+		}
+	}
+}
@@ -2,16 +2,26 @@ package repos
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 )
 
+type qualifier struct {
+	key      string
+	value    string
+	negated  bool
+	multiple bool
+}
+
 type SearchParams struct {
-	internal map[string]string
+	internal []qualifier
 }
 
 func NewSearchParams(options ...func(*SearchParams)) *SearchParams {
 	searchParams := &SearchParams{
-		internal: map[string]string{},
+		internal: []qualifier{},
 	}
 
 	for _, option := range options {
@@ -29,19 +39,58 @@ func (searchParams *SearchParams) Set(options ...func(*SearchParams)) {
 
 func (from *SearchParams) Copy() *SearchParams {
 	to := &SearchParams{
-		internal: map[string]string{},
+		internal: make([]qualifier, len(from.internal)),
 	}
 
-	for k, v := range from.internal {
-		to.internal[k] = v
-	}
+	copy(to.internal, from.internal)
 
 	return to
 }
 
+// put replaces every value of a single valued key, and appends to keys that
+// may be repeated such as language.
+func (searchParams *SearchParams) put(param qualifier) {
+	kept := searchParams.internal[:0]
+	for _, existing := range searchParams.internal {
+		if existing.key == param.key && (!param.multiple || existing.value == param.value) {
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	searchParams.internal = append(kept, param)
+}
+
 func setParam(key string, value string) func(*SearchParams) {
 	return func(search *SearchParams) {
-		search.internal[key] = value
+		search.put(qualifier{key: key, value: value})
+	}
+}
+
+func addParam(key string, value string) func(*SearchParams) {
+	return func(search *SearchParams) {
+		search.put(qualifier{key: key, value: value, multiple: true})
+	}
+}
+
+// Qualifier sets any qualifier, for searches of other kinds that share the
+// syntax such as the issue search. Values of repeatable keys are added to the
+// ones the key already has instead of replacing them.
+func Qualifier(key string, value string, repeatable bool) func(*SearchParams) {
+	if repeatable {
+		return addParam(key, value)
+	}
+	return setParam(key, value)
+}
+
+// Not negates the qualifiers set by the given options, e.g.
+// Not(Language("go")) yields -language:go.
+func Not(options ...func(*SearchParams)) func(*SearchParams) {
+	return func(search *SearchParams) {
+		negated := NewSearchParams(options...)
+		for _, param := range negated.internal {
+			param.negated = !param.negated
+			search.put(param)
+		}
 	}
 }
 
@@ -77,17 +126,219 @@ func Template(value bool) func(*SearchParams) {
 	return setParam("template", fmt.Sprint(value))
 }
 
-func (searchParams SearchParams) ToString() string {
-	var pairs []string
-	query := ""
-	for key, value := range searchParams.internal {
-		if key == "query" {
-			query = value + "+"
+func Archived(value bool) func(*SearchParams) {
+	return setParam("archived", fmt.Sprint(value))
+}
+
+func Language(value string) func(*SearchParams) {
+	return addParam("language", value)
+}
+
+func Topic(value string) func(*SearchParams) {
+	return addParam("topic", value)
+}
+
+func Topics(value Int) func(*SearchParams) {
+	return setParam("topics", value.value)
+}
+
+func License(value string) func(*SearchParams) {
+	return addParam("license", value)
+}
+
+// Size is the repository size in kilobytes.
+func Size(value Int) func(*SearchParams) {
+	return setParam("size", value.value)
+}
+
+func Forks(value Int) func(*SearchParams) {
+	return setParam("forks", value.value)
+}
+
+func Followers(value Int) func(*SearchParams) {
+	return setParam("followers", value.value)
+}
+
+func GoodFirstIssues(value Int) func(*SearchParams) {
+	return setParam("good-first-issues", value.value)
+}
+
+func HelpWantedIssues(value Int) func(*SearchParams) {
+	return setParam("help-wanted-issues", value.value)
+}
+
+func In(values ...InValue) func(*SearchParams) {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = value.value
+	}
+	return setParam("in", strings.Join(fields, ","))
+}
+
+func User(login string) func(*SearchParams) {
+	return addParam("user", login)
+}
+
+func Org(login string) func(*SearchParams) {
+	return addParam("org", login)
+}
+
+func Repo(fullName string) func(*SearchParams) {
+	return addParam("repo", fullName)
+}
+
+// String renders the search the way it is typed into the GitHub search box:
+// the free text followed by the qualifiers sorted by key, negation and value,
+// so equal searches always render the same.
+func (searchParams SearchParams) String() string {
+	var terms []string
+	var params []qualifier
+	for _, param := range searchParams.internal {
+		if param.key == "query" {
+			terms = append(terms, param.value)
 		} else {
-			pairs = append(pairs, key+":"+value)
+			params = append(params, param)
+		}
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		if params[i].negated != params[j].negated {
+			return !params[i].negated
+		}
+		return params[i].value < params[j].value
+	})
+
+	for _, param := range params {
+		value := param.value
+		if strings.ContainsAny(value, " \t") {
+			value = `"` + value + `"`
+		}
+
+		term := param.key + ":" + value
+		if param.negated {
+			term = "-" + term
+		}
+		terms = append(terms, term)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// ToString is the canonical URL encoded form of the search, ready for the q
+// parameter.
+func (searchParams SearchParams) ToString() string {
+	return url.QueryEscape(searchParams.String())
+}
+
+// Qualifiers that GitHub accepts more than once in the same search.
+var repeatableKeys = map[string]bool{
+	"language": true,
+	"topic":    true,
+	"license":  true,
+	"user":     true,
+	"org":      true,
+	"repo":     true,
+}
+
+// ParseSearchQuery reads a search as typed into GitHub, as the q parameter of
+// a search URL, or as a whole search URL, into SearchParams. Free text is kept
+// as the query and every key:value term becomes a qualifier.
+func ParseSearchQuery(raw string) (*SearchParams, error) {
+	raw = strings.TrimSpace(raw)
+
+	if strings.Contains(raw, "?") || strings.HasPrefix(raw, "q=") {
+		values, err := url.ParseQuery(raw[strings.Index(raw, "?")+1:])
+		if err != nil {
+			return nil, err
+		}
+		if !values.Has("q") {
+			return nil, fmt.Errorf("no q parameter in %q", raw)
 		}
+		raw = values.Get("q")
+	} else if escapeSequence.MatchString(raw) && !strings.ContainsAny(raw, " \t") {
+		unescaped, err := unescapeSearch(raw)
+		if err != nil {
+			return nil, err
+		}
+		raw = unescaped
+	}
+
+	tokens, err := splitSearchTerms(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	searchParams := NewSearchParams()
+	var text []string
+	for _, token := range tokens {
+		negated := strings.HasPrefix(token, "-")
+		key, value, found := strings.Cut(strings.TrimPrefix(token, "-"), ":")
+		if !found || key == "" || value == "" || strings.ContainsAny(key, "\" ") {
+			text = append(text, token)
+			continue
+		}
+
+		searchParams.put(qualifier{
+			key:      key,
+			value:    strings.Trim(value, `"`),
+			negated:  negated,
+			multiple: repeatableKeys[key],
+		})
+	}
+
+	if len(text) > 0 {
+		searchParams.Set(Query(strings.Join(text, " ")))
 	}
-	return query + strings.Join(pairs, "+")
+
+	return searchParams, nil
+}
+
+// escapeSequence tells a URL encoded search from one typed as is.
+var escapeSequence = regexp.MustCompile(`%[0-9A-Fa-f]{2}`)
+
+// unescapeSearch decodes a search that was URL encoded. A + is a space only
+// in query encoding, as ToString writes, which escapes spaces as + and a
+// literal + as %2B. Searches with %20 in them were path encoded, so their +
+// are kept, as in language:c++.
+func unescapeSearch(raw string) (string, error) {
+	if strings.Contains(raw, "%20") {
+		return url.PathUnescape(raw)
+	}
+	return url.QueryUnescape(raw)
+}
+
+// splitSearchTerms splits on whitespace outside of double quotes.
+func splitSearchTerms(raw string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unbalanced quotes in %q", raw)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens, nil
 }
 
 type FetchReposParams struct {
@@ -0,0 +1,79 @@
+package repos
+
+import "testing"
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"typed", "language:go stars:>100", "language:go stars:>100"},
+		{"plus kept when typed", "language:c++", "language:c++"},
+		{"query encoded", "language%3Ac%2B%2B+stars%3A%3E100", "language:c++ stars:>100"},
+		{"plus kept when path encoded", "language%3Ac++%20stars%3A%3E100", "language:c++ stars:>100"},
+		{"q parameter", "q=language%3Ago", "language:go"},
+		{"search URL", "https://github.com/search?q=language%3Ac%2B%2B+stars%3A%3E100&type=repositories", "language:c++ stars:>100"},
+		{"free text first", "stars:>=100 machine learning", "machine learning stars:>=100"},
+		{"negated", "-topic:tutorial language:python", "language:python -topic:tutorial"},
+		{"quoted value", `topic:"machine learning"`, `topic:"machine learning"`},
+		{"quotes dropped without spaces", `in:"readme"`, "in:readme"},
+		{"repeated language", "language:rust language:go", "language:go language:rust"},
+		{"single valued replaced", "stars:1..10 stars:>5", "stars:>5"},
+		{"surrounding space", "  fork:true  ", "fork:true"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			search, err := ParseSearchQuery(test.raw)
+			if err != nil {
+				t.Fatalf("ParseSearchQuery(%q) failed: %v", test.raw, err)
+			}
+			if got := search.String(); got != test.want {
+				t.Errorf("ParseSearchQuery(%q) = %q, want %q", test.raw, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"unbalanced quotes", `topic:"machine learning`},
+		{"URL without q", "https://github.com/search?type=repositories"},
+		{"invalid escape", "q=language%3Ac%2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseSearchQuery(test.raw); err == nil {
+				t.Errorf("ParseSearchQuery(%q) succeeded, want an error", test.raw)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryRoundTrip(t *testing.T) {
+	tests := []*SearchParams{
+		NewSearchParams(Language("c++")),
+		NewSearchParams(Query("machine learning"), Language("python"), Not(Topic("tutorial"))),
+		NewSearchParams(Topic("machine learning"), Stars(Int{}.Min(100))),
+		NewSearchParams(Language("c#"), Language("f#"), Fork(false)),
+	}
+
+	for _, search := range tests {
+		t.Run(search.String(), func(t *testing.T) {
+			for _, raw := range []string{search.String(), search.ToString()} {
+				parsed, err := ParseSearchQuery(raw)
+				if err != nil {
+					t.Fatalf("ParseSearchQuery(%q) failed: %v", raw, err)
+				}
+				if parsed.String() != search.String() {
+					t.Errorf("ParseSearchQuery(%q) = %q, want %q", raw, parsed.String(), search.String())
+				}
+			}
+		})
+	}
+}
@@ -17,9 +17,20 @@ type IsValue struct {
 	value string
 }
 
+func (value Int) String() string  { return value.value }
+func (value Time) String() string { return value.value }
+
 func Public() IsValue  { return IsValue{"public"} }
 func Private() IsValue { return IsValue{"private"} }
 
+type InValue struct {
+	value string
+}
+
+func InName() InValue        { return InValue{"name"} }
+func InDescription() InValue { return InValue{"description"} }
+func InReadme() InValue      { return InValue{"readme"} }
+
 func (Int) Range(from, to int) Int {
 	return Int{fmt.Sprintf("%d..%d", from, to)}
 }
@@ -0,0 +1,204 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Sink is a dataset that rows are written into, whatever the format. Rows
+// written may be buffered until Flush.
+type Sink interface {
+	// Open starts the dataset at path, given without an extension such as
+	// data/comments, for rows of the given type.
+	Open(path string, rowType reflect.Type) error
+	Write(row any) error
+	Flush() error
+	Close() error
+}
+
+// Appender is a sink that can add to a dataset left by an earlier run instead
+// of starting over, as comments -resume does.
+type Appender interface {
+	Append(path string, rowType reflect.Type) error
+}
+
+var sinks = map[string]func() Sink{
+	"csv":       func() Sink { return &CSVSink{} },
+	"parquet":   func() Sink { return NewParquetSink() },
+	"sqlite":    func() Sink { return &SQLiteSink{} },
+	"jsonl":     func() Sink { return &JSONLSink{Extension: "jsonl"} },
+	"jsonl.gz":  func() Sink { return &JSONLSink{Extension: "jsonl.gz"} },
+	"jsonl.zst": func() Sink { return &JSONLSink{Extension: "jsonl.zst"} },
+}
+
+// RegisterSink makes a format available by name, which is both its extension
+// and its URI scheme.
+func RegisterSink(name string, newSink func() Sink) {
+	sinks[name] = newSink
+}
+
+// Formats lists the names of the registered sinks.
+func Formats() []string {
+	names := make([]string, 0, len(sinks))
+	for name := range sinks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// OpenSink starts the dataset at path in the given format, either an extension
+// such as jsonl.gz or a URI such as csv://out, whose location is the directory
+// the dataset goes into instead of the one of path. Rows are of the type of
+// model, e.g. CommentData{}.
+func OpenSink(format string, path string, model any) (Sink, error) {
+	sink, path, err := newSink(format, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sink.Open(path, reflect.TypeOf(model)); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// AppendSink opens the dataset at path as OpenSink does, but keeps the rows
+// already there. It fails for formats that cannot be appended to.
+func AppendSink(format string, path string, model any) (Sink, error) {
+	sink, path, err := newSink(format, path)
+	if err != nil {
+		return nil, err
+	}
+
+	appender, ok := sink.(Appender)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot be appended to", format)
+	}
+
+	if err := appender.Append(path, reflect.TypeOf(model)); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// OpenInputSink opens a dataset that later commands read, such as data/repos.
+// Those read CSV at path, so it is written there too whatever the format.
+func OpenInputSink(format string, path string, model any) (Sink, error) {
+	if format == "csv" {
+		return OpenSink(format, path, model)
+	}
+
+	input, err := OpenSink("csv", path, model)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := OpenSink(format, path, model)
+	if err != nil {
+		input.Close()
+		return nil, err
+	}
+
+	return multiSink{input, output}, nil
+}
+
+func newSink(format string, path string) (Sink, string, error) {
+	name, location, isURI := strings.Cut(format, "://")
+	name = strings.TrimPrefix(name, ".")
+
+	newSink, ok := sinks[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+
+	if isURI && location != "" {
+		if err := os.MkdirAll(location, 0755); err != nil {
+			return nil, "", err
+		}
+		path = filepath.Join(location, filepath.Base(path))
+	}
+
+	return newSink(), path, nil
+}
+
+// WriteRows writes rows and flushes them, so they are on disk once it returns.
+func WriteRows[T any](sink Sink, rows ...T) error {
+	for _, row := range rows {
+		if err := sink.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return sink.Flush()
+}
+
+// checkRowType fails for rows of another type than the dataset was opened
+// for, whose columns would not line up.
+func checkRowType(row any, rowType reflect.Type) error {
+	if t := reflect.TypeOf(row); t != rowType {
+		return fmt.Errorf("cannot write %v into a dataset of %v", t, rowType)
+	}
+	return nil
+}
+
+// multiSink writes the same rows into several sinks.
+type multiSink []Sink
+
+func (sinks multiSink) Open(path string, rowType reflect.Type) error {
+	for _, sink := range sinks {
+		if err := sink.Open(path, rowType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sinks multiSink) Write(row any) error {
+	for _, sink := range sinks {
+		if err := sink.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sinks multiSink) Flush() error {
+	for _, sink := range sinks {
+		if err := sink.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sinks multiSink) Close() error {
+	var err error
+	for _, sink := range sinks {
+		if closeErr := sink.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// DefaultFormat is the format set in the DATA_FORMAT environment variable, csv
+// when unset.
+func DefaultFormat() string {
+	if format := os.Getenv("DATA_FORMAT"); format != "" {
+		return format
+	}
+	return "csv"
+}
+
+// FormatUsage is the help text of the -format flag, listing the registered
+// formats.
+func FormatUsage() string {
+	formats := Formats()
+	last := len(formats) - 1
+	return fmt.Sprintf("output format, %s or %s, or a URI such as jsonl.gz://out for another directory", strings.Join(formats[:last], ", "), formats[last])
+}
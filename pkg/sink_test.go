@@ -0,0 +1,161 @@
+package github
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeDataset opens the dataset at path in format with open, such as OpenSink
+// or AppendSink, writes rows into it and closes it, for tests to read back.
+func writeDataset[T any](t *testing.T, open func(string, string, any) (Sink, error), format string, path string, rows ...T) {
+	t.Helper()
+
+	var model T
+	sink, err := open(format, path, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteRows(sink, rows...); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSink(t *testing.T) {
+	tests := []struct {
+		format string
+		file   string
+	}{
+		{"csv", "users.csv"},
+		{".csv", "users.csv"},
+		{"jsonl.gz", "users.jsonl.gz"},
+		{"parquet", "users.parquet"},
+		{"sqlite", "github.sqlite"},
+		{"csv://out", "out/users.csv"},
+		{"jsonl.zst://out/nested", "out/nested/users.jsonl.zst"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			dir := t.TempDir()
+			format := strings.Replace(test.format, "://", "://"+dir+"/", 1)
+
+			sink, err := OpenSink(format, filepath.Join(dir, "users"), StarEvent{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, test.file)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	if _, err := OpenSink("xml", filepath.Join(t.TempDir(), "users"), StarEvent{}); err == nil {
+		t.Error("opened an unknown format")
+	}
+}
+
+func TestOpenInputSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users")
+
+	writeDataset(t, OpenInputSink, "jsonl", path, csvTestUser{ID: 1, Login: "octocat"})
+
+	rows, err := LoadFromCSV[csvTestUser](path + ".csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []csvTestUser{{1, "octocat"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("read %+v, want %+v", rows, want)
+	}
+	if _, err := os.Stat(path + ".jsonl"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONLAppend(t *testing.T) {
+	decompress := map[string]func(io.Reader) (io.Reader, error){
+		"jsonl": func(r io.Reader) (io.Reader, error) { return r, nil },
+		"jsonl.gz": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"jsonl.zst": func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+	}
+
+	for format, decompress := range decompress {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users")
+			want := []csvTestUser{{1, "octocat"}, {2, "hubot <bot>"}}
+
+			writeDataset(t, OpenSink, format, path, want[0])
+			writeDataset(t, AppendSink, format, path, want[1])
+
+			file, err := os.Open(path + "." + format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			reader, err := decompress(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []csvTestUser
+			scanner := bufio.NewScanner(reader)
+			for scanner.Scan() {
+				var row csvTestUser
+				if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+					t.Fatalf("line %q: %v", scanner.Text(), err)
+				}
+				got = append(got, row)
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("read %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteOtherRowType(t *testing.T) {
+	for _, format := range []string{"csv", "parquet", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			sink, err := OpenSink(format, filepath.Join(t.TempDir(), "rows"), csvTestRow{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sink.Close()
+
+			if err := sink.Write(csvTestUser{}); err == nil {
+				t.Error("wrote a row of another type")
+			}
+		})
+	}
+}
+
+func TestAppendSinkParquet(t *testing.T) {
+	if _, err := AppendSink("parquet", filepath.Join(t.TempDir(), "users"), csvTestUser{}); err == nil {
+		t.Error("appended to Parquet")
+	}
+}
@@ -0,0 +1,348 @@
+package github
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteRecord is implemented by dataset rows that know where they go in the
+// normalized schema.
+type SQLiteRecord interface {
+	SQLiteUpserts() []Upsert
+}
+
+// Upsert inserts a row into a table or, when a row with the same key is
+// already there, updates the given columns of it. Columns left out are kept,
+// so rows can be filled in by different commands.
+type Upsert struct {
+	Table  string
+	Key    []string
+	Values map[string]any
+}
+
+// SQLiteSink upserts rows into the github.sqlite database next to the
+// dataset, shared by every command, so reruns update rows instead of
+// duplicating them.
+type SQLiteSink struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS repos (
+		id INTEGER PRIMARY KEY,
+		name TEXT,
+		full_name TEXT,
+		stargazers_count INTEGER,
+		matched_queries TEXT,
+		population TEXT,
+		as_of TEXT,
+		stars_as_of INTEGER,
+		last_push_as_of TEXT
+	);
+	CREATE INDEX IF NOT EXISTS repos_full_name ON repos (full_name);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY,
+		login TEXT
+	);
+	CREATE INDEX IF NOT EXISTS users_login ON users (login);
+
+	CREATE TABLE IF NOT EXISTS issues (
+		id INTEGER PRIMARY KEY,
+		repo_id INTEGER NOT NULL REFERENCES repos (id),
+		number INTEGER NOT NULL,
+		user_id INTEGER REFERENCES users (id),
+		interval INTEGER,
+		body TEXT,
+		author_association TEXT,
+		UNIQUE (repo_id, number)
+	);
+	CREATE INDEX IF NOT EXISTS issues_user_id ON issues (user_id);
+
+	CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY,
+		issue_id INTEGER NOT NULL REFERENCES issues (id),
+		repo_id INTEGER NOT NULL REFERENCES repos (id),
+		user_id INTEGER REFERENCES users (id),
+		interval INTEGER,
+		body TEXT,
+		author_association TEXT
+	);
+	CREATE INDEX IF NOT EXISTS comments_issue_id ON comments (issue_id);
+	CREATE INDEX IF NOT EXISTS comments_repo_id ON comments (repo_id);
+	CREATE INDEX IF NOT EXISTS comments_user_id ON comments (user_id);
+
+	CREATE TABLE IF NOT EXISTS stars (
+		repo_id INTEGER NOT NULL REFERENCES repos (id),
+		user_id INTEGER NOT NULL REFERENCES users (id),
+		starred_at TEXT NOT NULL,
+		PRIMARY KEY (repo_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS stars_user_id ON stars (user_id);
+	CREATE INDEX IF NOT EXISTS stars_starred_at ON stars (starred_at);
+
+	CREATE TABLE IF NOT EXISTS commits (
+		repo_id INTEGER NOT NULL REFERENCES repos (id),
+		sha TEXT NOT NULL,
+		interval INTEGER,
+		author_name TEXT,
+		author_email TEXT,
+		author_login TEXT,
+		author_date TEXT,
+		committer_name TEXT,
+		committer_email TEXT,
+		committer_login TEXT,
+		committer_date TEXT,
+		message TEXT,
+		parents TEXT,
+		verified INTEGER,
+		verification_reason TEXT,
+		additions INTEGER,
+		deletions INTEGER,
+		files_changed INTEGER,
+		files_truncated INTEGER,
+		PRIMARY KEY (repo_id, sha)
+	);
+	CREATE INDEX IF NOT EXISTS commits_author_login ON commits (author_login);
+
+	-- weekly series per repo, e.g. stars or commits, each numbered from its own start
+	CREATE TABLE IF NOT EXISTS intervals (
+		repo_id INTEGER NOT NULL REFERENCES repos (id),
+		series TEXT NOT NULL,
+		interval INTEGER NOT NULL,
+		starts_at TEXT,
+		value INTEGER,
+		value_min INTEGER,
+		value_max INTEGER,
+		estimated INTEGER,
+		PRIMARY KEY (repo_id, series, interval)
+	);
+
+	CREATE TABLE IF NOT EXISTS partitions (
+		search TEXT PRIMARY KEY,
+		query TEXT,
+		total_count INTEGER,
+		fetched INTEGER,
+		retries INTEGER,
+		incomplete_pages INTEGER,
+		complete INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS samples (
+		repo_id INTEGER PRIMARY KEY REFERENCES repos (id)
+	);
+
+	CREATE TABLE IF NOT EXISTS star_anomalies (
+		repo_id INTEGER PRIMARY KEY REFERENCES repos (id),
+		stars INTEGER,
+		burst_intervals INTEGER,
+		max_z_score REAL,
+		new_accounts INTEGER,
+		empty_accounts INTEGER,
+		clustered_accounts INTEGER,
+		suspicious_stars INTEGER,
+		suspicious_share REAL,
+		suspicious INTEGER
+	);
+`
+
+var sqliteRecordType = reflect.TypeFor[SQLiteRecord]()
+
+// Open opens the database, creating the schema if needed. Rows must implement
+// SQLiteRecord.
+func (sink *SQLiteSink) Open(path string, rowType reflect.Type) error {
+	if !rowType.Implements(sqliteRecordType) {
+		return fmt.Errorf("%s cannot be written to SQLite", rowType)
+	}
+
+	filename := filepath.Join(filepath.Dir(path), "github.sqlite")
+	db, err := sql.Open("sqlite", "file:"+filename+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return err
+	}
+
+	*sink = SQLiteSink{db: db}
+	return nil
+}
+
+// Append is Open, rows are upserted into what is already there.
+func (sink *SQLiteSink) Append(path string, rowType reflect.Type) error {
+	return sink.Open(path, rowType)
+}
+
+// Write runs the upserts of a row in the pending transaction, rolling back
+// everything since the last flush when one fails.
+func (sink *SQLiteSink) Write(row any) error {
+	record, ok := row.(SQLiteRecord)
+	if !ok {
+		return fmt.Errorf("%T cannot be written to SQLite", row)
+	}
+
+	if sink.tx == nil {
+		tx, err := sink.db.Begin()
+		if err != nil {
+			return err
+		}
+		sink.tx = tx
+	}
+
+	for _, upsert := range record.SQLiteUpserts() {
+		if err := upsert.exec(sink.tx); err != nil {
+			sink.tx.Rollback()
+			sink.tx = nil
+			return fmt.Errorf("upsert into %s: %w", upsert.Table, err)
+		}
+	}
+
+	return nil
+}
+
+// Flush commits the rows upserted since the last flush.
+func (sink *SQLiteSink) Flush() error {
+	if sink.tx == nil {
+		return nil
+	}
+
+	err := sink.tx.Commit()
+	sink.tx = nil
+	return err
+}
+
+func (sink *SQLiteSink) Close() error {
+	if err := sink.Flush(); err != nil {
+		sink.db.Close()
+		return err
+	}
+
+	return sink.db.Close()
+}
+
+func (upsert Upsert) exec(tx *sql.Tx) error {
+	columns := make([]string, 0, len(upsert.Values))
+	for column := range upsert.Values {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+
+	placeholders := make([]string, len(columns))
+	values := make([]any, len(columns))
+	updates := []string{}
+	for i, column := range columns {
+		placeholders[i] = "?"
+		values[i] = sqliteValue(upsert.Values[column])
+		if !slices.Contains(upsert.Key, column) {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	}
+
+	conflict := "DO NOTHING"
+	if len(updates) > 0 {
+		conflict = "DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s",
+		upsert.Table,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(upsert.Key, ", "),
+		conflict,
+	)
+
+	_, err := tx.Exec(query, values...)
+	return err
+}
+
+// sqliteValue stores times as RFC 3339 text, as in CSV, and zero times as
+// null.
+func sqliteValue(value any) any {
+	if t, ok := value.(time.Time); ok {
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return value
+}
+
+// SQLiteUpserts writes a partition of the repo search into partitions.
+func (report PartitionReport) SQLiteUpserts() []Upsert {
+	return []Upsert{{
+		Table: "partitions",
+		Key:   []string{"search"},
+		Values: map[string]any{
+			"search":           report.Search,
+			"query":            report.Query,
+			"total_count":      report.TotalCount,
+			"fetched":          report.Fetched,
+			"retries":          report.Retries,
+			"incomplete_pages": report.IncompletePages,
+			"complete":         report.Complete,
+		},
+	}}
+}
+
+// SQLiteUpserts writes a star history into intervals under its series.
+func (history StarHistory) SQLiteUpserts() []Upsert {
+	series := history.Series
+	if series == "" {
+		series = "stars"
+	}
+
+	return []Upsert{
+		RepoUpsert(history.RepoID),
+		{
+			Table: "intervals",
+			Key:   []string{"repo_id", "series", "interval"},
+			Values: map[string]any{
+				"repo_id":   history.RepoID,
+				"series":    series,
+				"interval":  history.Interval,
+				"starts_at": StarIntervalStart(history.Interval),
+				"value":     history.Stars,
+				"value_min": history.StarsMin,
+				"value_max": history.StarsMax,
+				"estimated": history.Estimated,
+			},
+		},
+	}
+}
+
+func (event StarEvent) SQLiteUpserts() []Upsert {
+	return []Upsert{
+		RepoUpsert(event.RepoID),
+		UserUpsert(User{ID: event.UserID, Login: event.UserLogin}),
+		{
+			Table: "stars",
+			Key:   []string{"repo_id", "user_id"},
+			Values: map[string]any{
+				"repo_id":    event.RepoID,
+				"user_id":    event.UserID,
+				"starred_at": event.StarredAt,
+			},
+		},
+	}
+}
+
+// RepoUpsert makes sure a repo exists before rows referencing it are written,
+// without touching what is known about it.
+func RepoUpsert(id int) Upsert {
+	return Upsert{Table: "repos", Key: []string{"id"}, Values: map[string]any{"id": id}}
+}
+
+// UserUpsert records the login of a user.
+func UserUpsert(user User) Upsert {
+	return Upsert{Table: "users", Key: []string{"id"}, Values: map[string]any{"id": user.ID, "login": user.Login}}
+}
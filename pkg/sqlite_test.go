@@ -0,0 +1,103 @@
+package github
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sqliteTestRepo fills in a repo the way the repos command does.
+type sqliteTestRepo struct {
+	ID       int
+	FullName string
+}
+
+func (repo sqliteTestRepo) SQLiteUpserts() []Upsert {
+	return []Upsert{{
+		Table:  "repos",
+		Key:    []string{"id"},
+		Values: map[string]any{"id": repo.ID, "full_name": repo.FullName},
+	}}
+}
+
+func TestSQLiteUpserts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rows")
+	starredAt := time.Date(2016, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	writeDataset(t, AppendSink, "sqlite", path, sqliteTestRepo{ID: 1, FullName: "octo/cat"})
+	for run := 0; run < 2; run++ {
+		writeDataset(t, AppendSink, "sqlite", path,
+			StarEvent{RepoID: 1, UserID: 10, UserLogin: "hubot", StarredAt: starredAt},
+			StarEvent{RepoID: 1, UserID: 11, UserLogin: "octocat", StarredAt: starredAt},
+		)
+		writeDataset(t, AppendSink, "sqlite", path, StarHistory{RepoID: 1, Stars: 2 + run, Interval: 9, StarsMin: 2, StarsMax: 2 + run})
+	}
+	writeDataset(t, AppendSink, "sqlite", path, StarHistory{RepoID: 1, Stars: 1, Interval: 9, Series: "stars_clean"})
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "github.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		want  any
+	}{
+		{"rerun adds no stars", "SELECT count(*) FROM stars", 2},
+		{"users written once", "SELECT count(*) FROM users", 2},
+		{"repo kept", "SELECT count(*) FROM repos", 1},
+		{"repo columns left out are kept", "SELECT full_name FROM repos WHERE id = 1", "octo/cat"},
+		{"time as RFC 3339", "SELECT starred_at FROM stars WHERE user_id = 10", "2016-03-01T12:00:00Z"},
+		{"series told apart", "SELECT count(*) FROM intervals", 2},
+		{"rerun updates values", "SELECT value FROM intervals WHERE series = 'stars'", 3},
+		{"interval start", "SELECT starts_at FROM intervals WHERE series = 'stars'", "2016-02-26T00:00:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got any
+			switch test.want.(type) {
+			case int:
+				var n int
+				err = db.QueryRow(test.query).Scan(&n)
+				got = n
+			case string:
+				var s string
+				err = db.QueryRow(test.query).Scan(&s)
+				got = s
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("%s = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteRejects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows")
+
+	if _, err := OpenSink("sqlite", path, csvTestUser{}); err == nil {
+		t.Error("opened SQLite for rows without upserts")
+	}
+
+	sink, err := OpenSink("sqlite", path, StarEvent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	// starred_at is NOT NULL, so the whole pending transaction is rolled back
+	if err := sink.Write(StarEvent{RepoID: 1, UserID: 10}); err == nil {
+		t.Error("wrote a star without a date")
+	}
+	if err := sink.Flush(); err != nil {
+		t.Errorf("Flush after a rollback failed: %v", err)
+	}
+}
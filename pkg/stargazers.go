@@ -0,0 +1,229 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/machinebox/graphql"
+)
+
+// FetchStargazers lists a page of stargazers, oldest first. The star media
+// type adds starred_at to every stargazer.
+func (client *Client) FetchStargazers(repoFullname string, perPage int, page int) ([]Star, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/stargazers?per_page=%d&page=%d", repoFullname, perPage, page)
+	resp, err := client.fetchAccept(url, "application/vnd.github.star+json")
+	if err != nil {
+		return nil, err
+	}
+
+	var stars []Star
+	err = json.Unmarshal(resp.Body, &stars)
+
+	if err != nil {
+		print(string(resp.Body))
+		return nil, err
+	}
+
+	return stars, nil
+}
+
+// ErrStargazerPageCap is returned when a repo has more stargazers than REST
+// can page through, so callers can estimate its history instead.
+var ErrStargazerPageCap = errors.New("more stargazers than REST lists")
+
+// FetchAllStargazers pages through every stargazer of a repo over REST, up to
+// the last page REST serves.
+func (client *Client) FetchAllStargazers(repoFullname string) ([]Star, error) {
+	stars := []Star{}
+
+	perPage := 100
+	for page := 1; ; page++ {
+		if page > maxStargazerPage {
+			return nil, ErrStargazerPageCap
+		}
+
+		pageStars, err := client.FetchStargazers(repoFullname, perPage, page)
+		if err != nil {
+			fmt.Println("Failed to fetch stargazers for", repoFullname, ":", err)
+			return nil, err
+		}
+
+		stars = append(stars, pageStars...)
+
+		if len(pageStars) < perPage {
+			break
+		}
+	}
+
+	return stars, nil
+}
+
+const stargazersQuery = `
+	query ($owner: String!, $name: String!, $cursor: String) {
+		repository(owner: $owner, name: $name) {
+			stargazers(first: 100, after: $cursor, orderBy: {field: STARRED_AT, direction: ASC}) {
+				edges {
+					starredAt
+					node {
+						login
+						databaseId
+					}
+				}
+				pageInfo { endCursor hasNextPage }
+			}
+		}
+	}
+`
+
+// FetchAllStargazersGraphQL pages through every stargazer of a repo over
+// GraphQL, oldest first.
+func (client *Client) FetchAllStargazersGraphQL(repoFullname string) ([]Star, error) {
+	owner, name, found := strings.Cut(repoFullname, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid repo name %q", repoFullname)
+	}
+
+	req := graphql.NewRequest(stargazersQuery)
+	req.Var("owner", owner)
+	req.Var("name", name)
+
+	stars := []Star{}
+
+	cursor := ""
+	for {
+		if cursor != "" {
+			req.Var("cursor", cursor)
+		}
+
+		var respData struct {
+			Repository struct {
+				Stargazers struct {
+					Edges []struct {
+						StarredAt time.Time `json:"starredAt"`
+						Node      struct {
+							Login      string `json:"login"`
+							DatabaseID int    `json:"databaseId"`
+						} `json:"node"`
+					} `json:"edges"`
+					PageInfo pageInfo `json:"pageInfo"`
+				} `json:"stargazers"`
+			} `json:"repository"`
+		}
+
+		if err := client.query(req, &respData); err != nil {
+			fmt.Println("Failed to fetch stargazers for", repoFullname, ":", err)
+			return nil, err
+		}
+
+		for _, edge := range respData.Repository.Stargazers.Edges {
+			stars = append(stars, Star{
+				StarredAt: edge.StarredAt,
+				User:      User{ID: edge.Node.DatabaseID, Login: edge.Node.Login},
+			})
+		}
+
+		if !respData.Repository.Stargazers.PageInfo.HasNextPage {
+			break
+		}
+		cursor = respData.Repository.Stargazers.PageInfo.EndCursor
+	}
+
+	return stars, nil
+}
+
+// REST stops paginating stargazers after this page.
+const maxStargazerPage = 400
+
+// MaxRESTStargazers is how many stargazers REST lists at most.
+const MaxRESTStargazers = maxStargazerPage * 100
+
+type StarSample struct {
+	Position  int
+	StarredAt time.Time
+}
+
+// StarSamples are stargazers taken from evenly spaced pages, enough to
+// estimate the star history of a repo too large to page through.
+type StarSamples struct {
+	Samples []StarSample
+	Total   int
+	At      time.Time
+}
+
+// SampleStargazers fetches the given number of evenly spaced stargazer pages
+// through REST page jumps, always including the first page. Total is the
+// current star count, which anchors the history past the last reachable page.
+func (client *Client) SampleStargazers(repoFullname string, total int, pages int) (*StarSamples, error) {
+	perPage := 100
+	lastPage := (total + perPage - 1) / perPage
+	if lastPage > maxStargazerPage {
+		lastPage = maxStargazerPage
+	}
+	if pages > lastPage {
+		pages = lastPage
+	}
+
+	samples := &StarSamples{Total: total, At: time.Now().UTC()}
+
+	previous := 0
+	for i := 0; i < pages; i++ {
+		page := 1
+		if pages > 1 {
+			page = 1 + i*(lastPage-1)/(pages-1)
+		}
+		if page == previous {
+			continue
+		}
+		previous = page
+
+		stars, err := client.FetchStargazers(repoFullname, perPage, page)
+		if err != nil {
+			fmt.Println("Failed to fetch stargazers for", repoFullname, ":", err)
+			return nil, err
+		}
+
+		for j, star := range stars {
+			samples.Samples = append(samples.Samples, StarSample{
+				Position:  (page-1)*perPage + j + 1,
+				StarredAt: star.StarredAt,
+			})
+		}
+	}
+
+	return samples, nil
+}
+
+// CountAt estimates how many stars the repo had at the given time by linear
+// interpolation between the samples around it. Low and high bound the true
+// count: low stargazers are known to have starred by then and the next known
+// one starred later. Inside a sampled page the bounds meet and the count is
+// exact.
+func (samples *StarSamples) CountAt(t time.Time) (float64, int, int) {
+	points := append(append([]StarSample{}, samples.Samples...), StarSample{Position: samples.Total + 1, StarredAt: samples.At})
+
+	low, lowAt := 0, time.Time{}
+	for _, point := range points {
+		if point.StarredAt.After(t) {
+			high := point.Position - 1
+			if high <= low {
+				return float64(low), low, low
+			}
+
+			if lowAt.IsZero() {
+				// nothing is known before the first sample
+				return float64(high), low, high
+			}
+
+			fraction := float64(t.Sub(lowAt)) / float64(point.StarredAt.Sub(lowAt))
+			estimate := float64(low) + fraction*float64(high-low)
+			return estimate, low, high
+		}
+
+		low, lowAt = point.Position, point.StarredAt
+	}
+
+	return float64(samples.Total), samples.Total, samples.Total
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StarsSince is where star histories start, the first of their intervals.
+var StarsSince = time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// StarHistory is the cumulative stars of a repo since 2016 at the end of an
+// interval. Estimated rows come from sampled pages, with the true count
+// somewhere between StarsMin and StarsMax.
+type StarHistory struct {
+	RepoID    int  `json:"repo_id"`
+	Stars     int  `json:"stars"`
+	Interval  int  `json:"interval"`
+	Estimated bool `json:"estimated"`
+	StarsMin  int  `json:"stars_min"`
+	StarsMax  int  `json:"stars_max"`
+	// Series tells histories of the same repo apart in SQLite, stars when
+	// empty.
+	Series string `json:"-" csv:"-"`
+}
+
+// StarEvent is a single star, kept with the user so stargazers can be joined
+// with commenters and followed across repos.
+type StarEvent struct {
+	RepoID    int       `json:"repo_id" csv:",required"`
+	UserID    int       `json:"user_id"`
+	UserLogin string    `json:"user_login"`
+	StarredAt time.Time `json:"starred_at" csv:",required"`
+}
+
+// LoadStarEvents reads star events, keeping each repo together and oldest
+// first as they were fetched.
+func LoadStarEvents(filename string) ([]StarEvent, error) {
+	starEvents, err := LoadFromCSV[StarEvent](filename)
+	if err != nil {
+		fmt.Println("Error reading CSV file:", err)
+		return nil, err
+	}
+
+	sort.SliceStable(starEvents, func(i, j int) bool {
+		if starEvents[i].RepoID != starEvents[j].RepoID {
+			return starEvents[i].RepoID < starEvents[j].RepoID
+		}
+		return starEvents[i].StarredAt.Before(starEvents[j].StarredAt)
+	})
+
+	return starEvents, nil
+}
+
+// AggregateStarEvents turns star events, grouped by repo and oldest first,
+// into the weekly cumulative stars of each repo since 2016.
+func AggregateStarEvents(starEvents []StarEvent) []StarHistory {
+	var stargazerHistories []StarHistory
+
+	for start := 0; start < len(starEvents); {
+		repoID := starEvents[start].RepoID
+		end := start
+		for end < len(starEvents) && starEvents[end].RepoID == repoID {
+			end++
+		}
+
+		lastInterval := 0
+		totalStars := 0
+		for _, sg := range starEvents[start:end] {
+			if sg.StarredAt.Year() < 2016 {
+				continue
+			} else if sg.StarredAt.Year() > 2019 {
+				break
+			}
+
+			interval := StarInterval(sg.StarredAt)
+			if interval != lastInterval {
+				// skip if no stars accumulated yet
+				if totalStars > 0 {
+					stargazerHistories = append(stargazerHistories, StarHistory{
+						RepoID:   repoID,
+						Stars:    totalStars,
+						Interval: lastInterval,
+						StarsMin: totalStars,
+						StarsMax: totalStars,
+					})
+				}
+				lastInterval = interval
+			}
+			totalStars++
+		}
+
+		start = end
+	}
+
+	return stargazerHistories
+}
+
+// StarInterval numbers the week a date falls in, from 1 for the first week
+// of 2016.
+func StarInterval(date time.Time) int {
+	weeks := int(date.Sub(StarsSince).Hours()/24/7) + 1
+	return weeks
+}
+
+// StarIntervalStart is the first day of an interval numbered by StarInterval.
+func StarIntervalStart(interval int) time.Time {
+	return StarsSince.AddDate(0, 0, 7*(interval-1))
+}
@@ -0,0 +1,55 @@
+package github
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStarInterval(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want int
+	}{
+		{time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2016, time.January, 7, 23, 59, 59, 0, time.UTC), 1},
+		{time.Date(2016, time.January, 8, 0, 0, 0, 0, time.UTC), 2},
+		{time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC), 53},
+	}
+
+	for _, test := range tests {
+		if got := StarInterval(test.date); got != test.want {
+			t.Errorf("StarInterval(%s) = %d, want %d", test.date, got, test.want)
+		}
+		if start := StarIntervalStart(test.want); start.After(test.date) || !start.AddDate(0, 0, 7).After(test.date) {
+			t.Errorf("StarIntervalStart(%d) = %s, which does not hold %s", test.want, start, test.date)
+		}
+	}
+}
+
+func TestAggregateStarEvents(t *testing.T) {
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	events := []StarEvent{
+		{RepoID: 1, StarredAt: day(2015, time.December, 31)},
+		{RepoID: 1, StarredAt: day(2016, time.January, 1)},
+		{RepoID: 1, StarredAt: day(2016, time.January, 2)},
+		{RepoID: 1, StarredAt: day(2016, time.January, 15)},
+		{RepoID: 1, StarredAt: day(2016, time.February, 1)},
+		{RepoID: 2, StarredAt: day(2016, time.January, 8)},
+		{RepoID: 2, StarredAt: day(2016, time.January, 9)},
+		{RepoID: 2, StarredAt: day(2020, time.January, 1)},
+	}
+
+	// the interval a repo is in when its stars run out is left open
+	want := []StarHistory{
+		{RepoID: 1, Stars: 2, Interval: 1, StarsMin: 2, StarsMax: 2},
+		{RepoID: 1, Stars: 3, Interval: 3, StarsMin: 3, StarsMax: 3},
+	}
+
+	if got := AggregateStarEvents(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("AggregateStarEvents = %+v, want %+v", got, want)
+	}
+}
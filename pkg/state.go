@@ -0,0 +1,118 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/machinebox/graphql"
+)
+
+// RepoState is what a repo looked like at a past date, rebuilt from its star
+// and commit timestamps rather than today's counters.
+type RepoState struct {
+	At         time.Time
+	Existed    bool
+	Stars      int
+	LastPushAt time.Time
+}
+
+const repoStateQuery = `
+	query ($owner: String!, $name: String!, $at: GitTimestamp!, $withHistory: Boolean!, $cursor: String) {
+		repository(owner: $owner, name: $name) {
+			createdAt
+			defaultBranchRef @include(if: $withHistory) {
+				target {
+					... on Commit {
+						history(first: 1, until: $at) {
+							nodes { committedDate }
+						}
+					}
+				}
+			}
+			stargazers(first: 100, after: $cursor, orderBy: {field: STARRED_AT, direction: ASC}) {
+				edges { starredAt }
+				pageInfo { endCursor hasNextPage }
+			}
+		}
+	}
+`
+
+// FetchRepoStateAt rebuilds the state of a repo at the given date: whether it
+// existed, how many stars it had from the stargazer timestamps, and its last
+// commit on the default branch before then. Stargazers are only paged until
+// the first one after the date.
+func (client *Client) FetchRepoStateAt(repoFullname string, at time.Time) (*RepoState, error) {
+	owner, name, found := strings.Cut(repoFullname, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid repo name %q", repoFullname)
+	}
+
+	req := graphql.NewRequest(repoStateQuery)
+	req.Var("owner", owner)
+	req.Var("name", name)
+	req.Var("at", at.UTC().Format(time.RFC3339))
+	req.Var("withHistory", true)
+
+	state := &RepoState{At: at}
+
+	cursor := ""
+	for {
+		if cursor != "" {
+			req.Var("cursor", cursor)
+		}
+
+		var respData struct {
+			Repository struct {
+				CreatedAt        time.Time `json:"createdAt"`
+				DefaultBranchRef *struct {
+					Target struct {
+						History struct {
+							Nodes []struct {
+								CommittedDate time.Time `json:"committedDate"`
+							} `json:"nodes"`
+						} `json:"history"`
+					} `json:"target"`
+				} `json:"defaultBranchRef"`
+				Stargazers struct {
+					Edges []struct {
+						StarredAt time.Time `json:"starredAt"`
+					} `json:"edges"`
+					PageInfo pageInfo `json:"pageInfo"`
+				} `json:"stargazers"`
+			} `json:"repository"`
+		}
+
+		if err := client.query(req, &respData); err != nil {
+			fmt.Println("Failed to fetch state for", repoFullname, ":", err)
+			return nil, err
+		}
+
+		repository := respData.Repository
+		if cursor == "" {
+			state.Existed = !repository.CreatedAt.After(at)
+			if !state.Existed {
+				return state, nil
+			}
+
+			if repository.DefaultBranchRef != nil && len(repository.DefaultBranchRef.Target.History.Nodes) > 0 {
+				state.LastPushAt = repository.DefaultBranchRef.Target.History.Nodes[0].CommittedDate
+			}
+			req.Var("withHistory", false)
+		}
+
+		for _, edge := range repository.Stargazers.Edges {
+			if edge.StarredAt.After(at) {
+				return state, nil
+			}
+			state.Stars++
+		}
+
+		if !repository.Stargazers.PageInfo.HasNextPage {
+			break
+		}
+		cursor = repository.Stargazers.PageInfo.EndCursor
+	}
+
+	return state, nil
+}
@@ -0,0 +1,92 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/machinebox/graphql"
+)
+
+// Number of users aliased into one query.
+const usersBatchSize = 50
+
+// FetchUsers fetches the profiles of many users through GraphQL, batched into
+// one query through aliases. repositoryOwner resolves a missing login to null
+// instead of failing the whole query, so deleted users and organizations are
+// simply left out.
+func (client *Client) FetchUsers(logins []string) ([]UserProfile, error) {
+	users := make([]UserProfile, 0, len(logins))
+	for start := 0; start < len(logins); start += usersBatchSize {
+		end := start + usersBatchSize
+		if end > len(logins) {
+			end = len(logins)
+		}
+
+		req := graphql.NewRequest(usersQuery(end - start))
+		for i, login := range logins[start:end] {
+			req.Var(fmt.Sprintf("l%d", i), login)
+		}
+
+		var respData map[string]*struct {
+			DatabaseID   int       `json:"databaseId"`
+			Login        string    `json:"login"`
+			CreatedAt    time.Time `json:"createdAt"`
+			Repositories struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"repositories"`
+			Followers struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"followers"`
+			Following struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"following"`
+		}
+
+		if err := client.query(req, &respData); err != nil {
+			fmt.Println("Failed to fetch users:", err)
+			return nil, err
+		}
+
+		for i := range logins[start:end] {
+			user := respData[fmt.Sprintf("u%d", i)]
+			if user == nil || user.Login == "" {
+				continue
+			}
+
+			users = append(users, UserProfile{
+				ID:          user.DatabaseID,
+				Login:       user.Login,
+				CreatedAt:   user.CreatedAt,
+				PublicRepos: user.Repositories.TotalCount,
+				Followers:   user.Followers.TotalCount,
+				Following:   user.Following.TotalCount,
+			})
+		}
+	}
+
+	return users, nil
+}
+
+func usersQuery(count int) string {
+	var vars, users strings.Builder
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			vars.WriteString(", ")
+		}
+		fmt.Fprintf(&vars, "$l%d: String!", i)
+		fmt.Fprintf(&users, "\t\tu%d: repositoryOwner(login: $l%d) {\n"+
+			"\t\t\t... on User {\n"+
+			"\t\t\t\tdatabaseId login createdAt\n"+
+			"\t\t\t\trepositories(ownerAffiliations: OWNER, privacy: PUBLIC) { totalCount }\n"+
+			"\t\t\t\tfollowers { totalCount }\n"+
+			"\t\t\t\tfollowing { totalCount }\n"+
+			"\t\t\t}\n"+
+			"\t\t}\n", i, i)
+	}
+
+	return fmt.Sprintf(`
+	query (%s) {
+%s	}
+`, vars.String(), users.String())
+}